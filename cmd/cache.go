@@ -0,0 +1,325 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/9roads/ccyolo/internal/cache"
+	"github.com/9roads/ccyolo/internal/config"
+	"github.com/9roads/ccyolo/internal/preset"
+	"github.com/spf13/cobra"
+)
+
+var (
+	cacheQuiet  bool
+	cacheMatch  string
+	cacheTool   string
+	cachePreset string
+)
+
+var cacheCmd = &cobra.Command{
+	Use:   "cache",
+	Short: "Manage the decision cache",
+}
+
+var cacheListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List cached decisions, most recently used first",
+	Run: func(cmd *cobra.Command, args []string) {
+		items := cacheSelect()
+		if len(items) == 0 {
+			fmt.Println("No cached decisions")
+			return
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "KEY\tAGE\tDECISION\tRISK\tPRESET\tTOOL\tSUBJECT")
+		for _, it := range items {
+			risk := "-"
+			if it.RiskCategory != "" {
+				risk = fmt.Sprintf("%d %s", it.RiskScore, it.RiskCategory)
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", it.Key, age(it.Timestamp), cachedDecision(it.Entry),
+				risk, orDash(it.Preset), orDash(it.Tool), truncateLine(it.Subject, 60))
+		}
+		w.Flush()
+	},
+}
+
+var cacheShowCmd = &cobra.Command{
+	Use:   "show KEY",
+	Short: "Show a cached decision",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		it := cacheFind(args[0])
+		fmt.Printf("Key:       %s\n", it.Key)
+		fmt.Printf("Decision:  %s\n", cachedDecision(it.Entry))
+		fmt.Printf("Reason:    %s\n", orDash(it.Reason))
+		fmt.Printf("Source:    %s\n", orDash(it.Source))
+		if it.RiskCategory != "" {
+			fmt.Printf("Risk:      %d (%s)\n", it.RiskScore, it.RiskCategory)
+		}
+		fmt.Printf("Tool:      %s\n", orDash(it.Tool))
+		fmt.Printf("Subject:   %s\n", orDash(it.Subject))
+		fmt.Printf("Preset:    %s (fingerprint %s)\n", orDash(it.Preset), orDash(it.Fingerprint))
+		if it.Project != "" {
+			fmt.Printf("Project:   %s\n", it.Project)
+		}
+		fmt.Printf("Cached:    %s (%s ago)\n", time.Unix(it.Timestamp, 0).Format(time.DateTime), age(it.Timestamp))
+		fmt.Printf("Last used: %s (%s ago)\n", time.Unix(it.Used, 0).Format(time.DateTime), age(it.Used))
+		cwd, _ := os.Getwd()
+		expires := time.Unix(it.Timestamp+it.Lifetime(config.LoadFor(cwd).CacheTTL), 0)
+		if time.Now().After(expires) {
+			fmt.Printf("Expired:   %s (served only as a stale fallback)\n", expires.Format(time.DateTime))
+		} else {
+			fmt.Printf("Expires:   %s\n", expires.Format(time.DateTime))
+		}
+	},
+}
+
+var cacheRmCmd = &cobra.Command{
+	Use:   "rm [KEY...]",
+	Short: "Remove cached decisions by key or pattern",
+	Long: `Remove cached decisions, to revoke a bad approval without clearing the
+whole cache. Name entries by key (or a unique key prefix), or select them
+with --match, --tool and --preset:
+
+  ccyolo cache rm --match 'git push*'
+  ccyolo cache rm --tool WebFetch --match '*pastebin*'`,
+	Run: func(cmd *cobra.Command, args []string) {
+		var keys []string
+		for _, arg := range args {
+			keys = append(keys, cacheFind(arg).Key)
+		}
+		if cacheMatch != "" || cacheTool != "" || cachePreset != "" {
+			for _, it := range cacheSelect() {
+				keys = append(keys, it.Key)
+			}
+		} else if len(args) == 0 {
+			fmt.Println("Error: name entries by key or select them with --match, --tool or --preset")
+			os.Exit(1)
+		}
+
+		if err := cache.Delete(keys...); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Removed %d cached decision(s)\n", len(keys))
+	},
+}
+
+var cacheClearCmd = &cobra.Command{
+	Use:   "clear",
+	Short: "Delete all cached decisions",
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := cache.Clear(); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		fmt.Println("Cache cleared")
+	},
+}
+
+var cacheStatsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Summarize the cache",
+	Run: func(cmd *cobra.Command, args []string) {
+		items := cacheSelect()
+		cwd, _ := os.Getwd()
+		cfg := config.LoadFor(cwd)
+
+		var size int64
+		if info, err := os.Stat(cache.Path()); err == nil {
+			size = info.Size()
+		}
+		fmt.Printf("File:      %s (%d KiB)\n", cache.Path(), size/1024)
+		fmt.Printf("Entries:   %d\n", len(items))
+		if len(items) == 0 {
+			return
+		}
+
+		allowed, denied, expired := 0, 0, 0
+		oldest := items[0].Timestamp
+		byPreset, byTool, bySource := map[string]int{}, map[string]int{}, map[string]int{}
+		for _, it := range items {
+			if it.Approve {
+				allowed++
+			} else if it.Deny {
+				denied++
+			}
+			if it.Expired(cfg.CacheTTL) {
+				expired++
+			}
+			if it.Timestamp < oldest {
+				oldest = it.Timestamp
+			}
+			byPreset[orDash(it.Preset)]++
+			byTool[orDash(it.Tool)]++
+			bySource[orDash(it.Source)]++
+		}
+		fmt.Printf("Decisions: %d allow, %d ask, %d deny\n", allowed, len(items)-allowed-denied, denied)
+		fmt.Printf("Expired:   %d (kept for the stale-cache fallback)\n", expired)
+		fmt.Printf("Oldest:    %s ago\n", age(oldest))
+		fmt.Printf("Presets:   %s\n", counts(byPreset))
+		fmt.Printf("Tools:     %s\n", counts(byTool))
+		fmt.Printf("Sources:   %s\n", counts(bySource))
+	},
+}
+
+var cacheExportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Write cached decisions as JSON to stdout",
+	Run: func(cmd *cobra.Command, args []string) {
+		items := cacheSelect()
+		if items == nil {
+			items = []cache.Item{}
+		}
+		data, err := json.MarshalIndent(items, "", "  ")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println(string(data))
+	},
+}
+
+var cacheCompactCmd = &cobra.Command{
+	Use:   "compact",
+	Short: "Drop expired entries and evict the least recently used",
+	Long: `Rewrite the cache with only live entries, within cache_max_entries and
+cache_max_bytes. The hook starts this in the background when the cache has
+grown past cache_max_bytes.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cwd, _ := os.Getwd()
+		kept, removed, err := cache.Compact(config.LoadFor(cwd))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if !cacheQuiet {
+			fmt.Printf("Cache compacted: %d entries kept, %d removed\n", kept, removed)
+		}
+	},
+}
+
+// cacheSelect lists the entries matching the --match, --tool and --preset
+// flags. --match is a simple pattern (prefix*, *suffix, *contains*) on the
+// subject.
+func cacheSelect() []cache.Item {
+	items, err := cache.List()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	var selected []cache.Item
+	for _, it := range items {
+		if cacheMatch != "" && !preset.MatchPattern(it.Subject, cacheMatch) ||
+			cacheTool != "" && it.Tool != cacheTool ||
+			cachePreset != "" && it.Preset != cachePreset {
+			continue
+		}
+		selected = append(selected, it)
+	}
+	return selected
+}
+
+// cacheFind returns the entry with a key or unique key prefix, or exits.
+func cacheFind(key string) cache.Item {
+	items, err := cache.List()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	var found []cache.Item
+	for _, it := range items {
+		if strings.HasPrefix(it.Key, key) {
+			found = append(found, it)
+		}
+	}
+	switch len(found) {
+	case 0:
+		fmt.Printf("Error: no cached decision %s\n", key)
+	case 1:
+		return found[0]
+	default:
+		fmt.Printf("Error: %s matches %d entries\n", key, len(found))
+	}
+	os.Exit(1)
+	return cache.Item{}
+}
+
+// cachedDecision names what a cached decision does: "ask" entries leave
+// the call to Claude Code.
+func cachedDecision(e cache.Entry) string {
+	switch {
+	case e.Approve:
+		return "allow"
+	case e.Deny:
+		return "deny"
+	}
+	return "ask"
+}
+
+func age(ts int64) string {
+	d := time.Since(time.Unix(ts, 0)).Round(time.Second)
+	switch {
+	case d >= 48*time.Hour:
+		return fmt.Sprintf("%dd", int(d.Hours()/24))
+	case d >= time.Hour:
+		return fmt.Sprintf("%dh", int(d.Hours()))
+	case d >= time.Minute:
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	}
+	return fmt.Sprintf("%ds", int(d.Seconds()))
+}
+
+func counts(m map[string]int) string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		return m[names[i]] > m[names[j]] || m[names[i]] == m[names[j]] && names[i] < names[j]
+	})
+	parts := make([]string, len(names))
+	for i, name := range names {
+		parts[i] = fmt.Sprintf("%s %d", name, m[name])
+	}
+	return strings.Join(parts, ", ")
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+func truncateLine(s string, n int) string {
+	s = strings.ReplaceAll(s, "\n", " ")
+	if len(s) > n {
+		return s[:n-3] + "..."
+	}
+	return s
+}
+
+func init() {
+	for _, c := range []*cobra.Command{cacheListCmd, cacheRmCmd, cacheStatsCmd, cacheExportCmd} {
+		c.Flags().StringVar(&cacheMatch, "match", "", "Only entries whose command, path or URL matches a pattern")
+		c.Flags().StringVar(&cacheTool, "tool", "", "Only entries for a tool")
+		c.Flags().StringVar(&cachePreset, "preset", "", "Only entries of a preset")
+	}
+	cacheCompactCmd.Flags().BoolVarP(&cacheQuiet, "quiet", "q", false, "Print nothing on success")
+	cacheCmd.AddCommand(cacheListCmd)
+	cacheCmd.AddCommand(cacheShowCmd)
+	cacheCmd.AddCommand(cacheRmCmd)
+	cacheCmd.AddCommand(cacheClearCmd)
+	cacheCmd.AddCommand(cacheStatsCmd)
+	cacheCmd.AddCommand(cacheExportCmd)
+	cacheCmd.AddCommand(cacheCompactCmd)
+	rootCmd.AddCommand(cacheCmd)
+}
@@ -1,10 +1,14 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
+	"os"
+	"strings"
 
 	"github.com/9roads/ccyolo/internal/claude"
 	"github.com/9roads/ccyolo/internal/config"
+	"github.com/9roads/ccyolo/internal/engine"
 	"github.com/9roads/ccyolo/internal/preset"
 	"github.com/9roads/ccyolo/internal/settings"
 	"github.com/spf13/cobra"
@@ -22,37 +26,31 @@ var checkCmd = &cobra.Command{
 
 		// 1. Check hook registration
 		fmt.Print("Hook registered:    ")
-		if settings.IsHookInstalled() {
-			fmt.Println("OK")
+		if event := settings.InstalledEvent(); event != "" {
+			fmt.Printf("OK (%s)\n", event)
 		} else {
 			fmt.Println("MISSING")
 			fmt.Println("  Run: ccyolo install")
 			allGood = false
 		}
 
-		// 2. Check API key
-		fmt.Print("API key:            ")
-		apiKey := config.GetAPIKey()
-		if apiKey != "" {
-			fmt.Println("configured")
-
-			// 3. Validate API key
-			fmt.Print("API key valid:      ")
-			if err := claude.ValidateAPIKey(apiKey); err != nil {
-				fmt.Printf("FAILED (%v)\n", err)
+		// 2. Check the provider
+		cwd, _ := os.Getwd()
+		cfg := config.LoadFor(cwd)
+		name, pc := cfg.ActiveProvider()
+		fmt.Printf("Provider:           %s (%s)\n", name, pc.Type)
+		fmt.Print("Provider reachable: ")
+		if err := claude.ValidateProvider(cfg); err != nil {
+			fmt.Printf("FAILED (%v)\n", err)
+			if errors.Is(err, claude.ErrNoAPIKey) {
 				fmt.Println("  Run: ccyolo setup")
-				allGood = false
-			} else {
-				fmt.Println("OK")
 			}
-		} else {
-			fmt.Println("MISSING")
-			fmt.Println("  Run: ccyolo setup")
 			allGood = false
+		} else {
+			fmt.Println("OK")
 		}
 
-		// 4. Check config
-		cfg := config.Load()
+		// 3. Check config
 		fmt.Print("Enabled:            ")
 		if cfg.Enabled {
 			fmt.Println("yes")
@@ -60,17 +58,45 @@ var checkCmd = &cobra.Command{
 			fmt.Println("no (run 'ccyolo enable' to enable)")
 		}
 
-		// 5. Check preset
+		// 4. Check preset
 		fmt.Printf("Preset:             %s\n", cfg.Preset)
-		p := preset.Get(cfg.Preset)
-		if p.Name == "" || p.Name == "balanced" && cfg.Preset != "balanced" {
-			fmt.Println("  Warning: preset not found, using 'balanced'")
+		if p, err := preset.Get(cfg.Preset, cfg.PresetDirs...); err != nil {
+			fmt.Printf("  Error: %v\n", err)
+			fmt.Println("  Calls are passed to Claude Code until the preset is fixed")
+			allGood = false
+		} else {
+			if p.Name == "balanced" && cfg.Preset != "balanced" {
+				fmt.Println("  Warning: preset not found, using 'balanced'")
+			}
+			if _, err := engine.New(p); err != nil {
+				fmt.Printf("  Error: %v\n", err)
+				fmt.Println("  Calls are passed to Claude Code until the preset is fixed")
+				allGood = false
+			}
 		}
 
-		// 6. Check model
-		fmt.Printf("Model:              %s\n", cfg.Model)
+		// 5. Check model
+		fmt.Printf("Model:              %s\n", cfg.ActiveModel())
+		if esc := cfg.Escalation; esc != nil && esc.Model != "" {
+			fmt.Printf("  escalates to %s\n", esc.Model)
+		}
+		if err := engine.ValidateOnError(cfg.OnError); err != nil {
+			fmt.Printf("  Error: %v\n", err)
+			allGood = false
+		}
+		for category := range cfg.CacheCategoryTTL {
+			if !containsCategory(category) {
+				fmt.Printf("  Error: cache_category_ttl: unknown risk category %q (want %s)\n",
+					category, strings.Join(claude.RiskCategories, ", "))
+				allGood = false
+			}
+		}
+		if cfg.CacheScope != "" && cfg.CacheScope != "global" && cfg.CacheScope != "project" {
+			fmt.Printf("  Error: cache_scope: want global or project, got %q\n", cfg.CacheScope)
+			allGood = false
+		}
 
-		// 7. Check logging
+		// 6. Check logging
 		fmt.Print("Logging:            ")
 		if cfg.Logging {
 			fmt.Println("enabled")
@@ -78,7 +104,7 @@ var checkCmd = &cobra.Command{
 			fmt.Println("disabled")
 		}
 
-		// 8. Check Claude Code settings path
+		// 7. Check Claude Code settings path
 		fmt.Printf("Claude settings:    %s\n", settings.ClaudeSettingsPath())
 
 		fmt.Println()
@@ -90,6 +116,15 @@ var checkCmd = &cobra.Command{
 	},
 }
 
+func containsCategory(category string) bool {
+	for _, c := range claude.RiskCategories {
+		if c == category {
+			return true
+		}
+	}
+	return false
+}
+
 func init() {
 	// Will be added in root.go
 }
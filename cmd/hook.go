@@ -1,18 +1,22 @@
 package cmd
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"strings"
 	"time"
 
 	"github.com/9roads/ccyolo/internal/cache"
-	"github.com/9roads/ccyolo/internal/claude"
 	"github.com/9roads/ccyolo/internal/config"
+	"github.com/9roads/ccyolo/internal/engine"
+	"github.com/9roads/ccyolo/internal/hook"
 	"github.com/9roads/ccyolo/internal/preset"
+	"github.com/9roads/ccyolo/internal/tools"
 	"github.com/spf13/cobra"
 )
 
@@ -40,15 +44,22 @@ func logMsg(format string, args ...interface{}) {
 	fmt.Fprintf(logFile, "[%s] %s\n", time.Now().Format("15:04:05"), msg)
 }
 
-type HookInput struct {
-	ToolName  string                 `json:"tool_name"`
-	ToolInput map[string]interface{} `json:"tool_input"`
+type HookSpecificOutput struct {
+	HookEventName string `json:"hookEventName"`
+
+	// PreToolUse
+	PermissionDecision string `json:"permissionDecision,omitempty"`
+	Reason             string `json:"permissionDecisionReason,omitempty"`
+
+	// PermissionRequest
+	Decision *PermissionRequestDecision `json:"decision,omitempty"`
 }
 
-type HookSpecificOutput struct {
-	HookEventName      string `json:"hookEventName"`
-	PermissionDecision string `json:"permissionDecision"`
-	Reason             string `json:"reason,omitempty"`
+// PermissionRequestDecision answers a PermissionRequest hook. It can only
+// allow or deny; to let the user decide, the hook outputs nothing.
+type PermissionRequestDecision struct {
+	Behavior string `json:"behavior"`
+	Message  string `json:"message,omitempty"`
 }
 
 type HookResponse struct {
@@ -57,7 +68,7 @@ type HookResponse struct {
 
 var hookCmd = &cobra.Command{
 	Use:    "hook",
-	Short:  "Handle Claude Code permission request (internal)",
+	Short:  "Handle Claude Code PreToolUse/PermissionRequest hook (internal)",
 	Hidden: true,
 	Run: func(cmd *cobra.Command, args []string) {
 		runHook()
@@ -65,11 +76,33 @@ var hookCmd = &cobra.Command{
 }
 
 func runHook() {
-	cfg := config.Load()
+	// Read raw input first; the project config depends on its cwd
+	rawInput, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "[ccyolo] read error:", err)
+		fmt.Println("{}")
+		return
+	}
+
+	// Parse input
+	var input hook.Input
+	parseErr := json.Unmarshal(rawInput, &input)
+
+	cfg := config.LoadFor(input.Cwd)
 	initLogging(cfg.Logging)
 
 	logMsg("=== hook called ===")
 	logMsg("config: enabled=%v, preset=%s", cfg.Enabled, cfg.Preset)
+	if cfg.ProjectRoot != "" {
+		if cfg.ProjectUntrusted {
+			logMsg("project config %s not trusted, ignoring (run 'ccyolo trust')", cfg.ProjectRoot)
+		} else {
+			logMsg("project config: %s", cfg.ProjectRoot)
+			if len(cfg.ProjectIgnored) > 0 {
+				logMsg("project config: ignoring %s", strings.Join(cfg.ProjectIgnored, ", "))
+			}
+		}
+	}
 
 	// If disabled, pass through
 	if !cfg.Enabled {
@@ -78,110 +111,108 @@ func runHook() {
 		return
 	}
 
-	// Read raw input first for logging
-	rawInput, err := io.ReadAll(os.Stdin)
-	if err != nil {
-		logMsg("read error: %v", err)
-		fmt.Println("{}")
-		return
-	}
 	logMsg("raw input: %s", string(rawInput))
-
-	// Parse input
-	var input HookInput
-	if err := json.Unmarshal(rawInput, &input); err != nil {
-		logMsg("parse error: %v", err)
-		fmt.Fprintln(os.Stderr, "[ccyolo] parse error:", err)
+	if parseErr != nil {
+		logMsg("parse error: %v", parseErr)
+		fmt.Fprintln(os.Stderr, "[ccyolo] parse error:", parseErr)
 		fmt.Println("{}")
 		return
 	}
 
-	toolName := input.ToolName
-	toolInput := input.ToolInput
-	logMsg("tool: %s, input: %+v", toolName, toolInput)
-
-	// Load preset
-	p := preset.Get(cfg.Preset)
-
-	// Step 1: Check static rules
-	ruleResult := preset.CheckRules(toolName, toolInput, p)
-	logMsg("rule check result: %v", ruleResult)
-
-	if ruleResult != nil {
-		if *ruleResult {
-			logMsg("rule ALLOW")
-			respond(true, "rule", toolName, toolInput)
-		} else {
-			logMsg("rule DENY, asking user")
-			fmt.Println("{}")
-		}
-		return
+	logMsg("session: %s, tool_use: %s, event: %s, mode: %s, cwd: %s",
+		input.SessionID, input.ToolUseID, input.HookEventName, input.PermissionMode, input.Cwd)
+	logMsg("tool: %s, input: %+v", input.ToolName, input.ToolInput)
+	if len(input.PermissionSuggestions) > 0 {
+		logMsg("permission suggestions: %d", len(input.PermissionSuggestions))
 	}
 
-	// Step 2: Check cache
-	cachedResult := cache.Get(toolName, toolInput, cfg.Preset)
-	logMsg("cache result: %v", cachedResult)
-	if cachedResult != nil {
-		if *cachedResult {
-			logMsg("cache ALLOW")
-			respond(true, "cached", toolName, toolInput)
-		} else {
-			logMsg("cache DENY")
-			fmt.Println("{}")
-		}
-		return
-	}
-
-	// Step 3: Ask Claude API
-	apiKey := config.GetAPIKey()
-	if apiKey == "" {
-		logMsg("no API key")
-		fmt.Fprintln(os.Stderr, "[ccyolo] no API key configured")
+	// Load preset
+	p, err := preset.Get(cfg.Preset, cfg.PresetDirs...)
+	if err != nil {
+		// Fail closed: a broken preset mustn't approve under another policy
+		logMsg("%v, passing to Claude Code", err)
+		fmt.Fprintln(os.Stderr, "[ccyolo]", err)
 		fmt.Println("{}")
 		return
 	}
-	logMsg("calling Claude API...")
 
-	result, reason, err := claude.EvaluateSafety(apiKey, cfg.Model, p.Prompt, toolName, toolInput)
+	eng, err := engine.New(p)
 	if err != nil {
-		logMsg("API error: %v", err)
-		fmt.Fprintln(os.Stderr, "[ccyolo] API error:", err)
+		logMsg("preset %s: %v, passing to Claude Code", p.Name, err)
+		fmt.Fprintf(os.Stderr, "[ccyolo] preset %s: %v\n", p.Name, err)
 		fmt.Println("{}")
 		return
 	}
-	logMsg("API result: %v, reason: %s", result, reason)
+	eng.Log = logMsg
 
-	// Cache the result
-	if result != nil {
-		cache.Set(toolName, toolInput, cfg.Preset, *result)
+	defer compactCache(cfg)
+
+	d, err := eng.Evaluate(context.Background(), engine.Request{Input: input, Preset: p, Config: cfg})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "[ccyolo] evaluation error:", err)
 	}
+	logMsg("decision: %s by %s (%s)", d.Action, d.Source, d.Reason)
 
-	if result != nil && *result {
-		logMsg("API ALLOW")
-		respond(true, "AI: "+reason, toolName, toolInput)
-	} else {
-		logMsg("API DENY or nil")
+	if d.Action == engine.Pass {
 		fmt.Println("{}")
+		return
 	}
+	respond(string(d.Action), d.Reason, input)
 }
 
-func respond(allow bool, reason, toolName string, toolInput map[string]interface{}) {
-	summary := getOperationSummary(toolName, toolInput)
-
-	decision := "deny"
-	if allow {
-		decision = "allow"
+// compactCache starts "ccyolo cache compact" in the background once the
+// cache has outgrown its limits, so the hook doesn't wait for it.
+func compactCache(cfg config.Config) {
+	if !cache.NeedsCompaction(cfg) {
+		return
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return
+	}
+	cmd := exec.Command(exe, "cache", "compact", "--quiet")
+	if err := cmd.Start(); err != nil {
+		logMsg("cache compaction: %v", err)
+		return
 	}
+	logMsg("cache compaction started (pid %d)", cmd.Process.Pid)
+	cmd.Process.Release()
+}
+
+// respond writes a decision ("allow", "ask" or "deny") in the schema of the
+// hook event being handled. The reason is shown to the user, and on deny to
+// Claude so it can change course.
+func respond(decision, reason string, input hook.Input) {
+	summary := tools.Summarize(input.ToolName, input.ToolInput)
 
 	msg := fmt.Sprintf("[YOLO] %s (%s)", summary, reason)
 	logMsg("respond: %s - %s", decision, msg)
 
-	response := HookResponse{
-		HookSpecificOutput: HookSpecificOutput{
-			HookEventName:      "PreToolUse",
-			PermissionDecision: decision,
-			Reason:             msg,
-		},
+	var response HookResponse
+	if input.HookEventName == hook.EventPermissionRequest {
+		if decision == "ask" {
+			// Claude Code shows its own prompt
+			fmt.Println("{}")
+			return
+		}
+		d := &PermissionRequestDecision{Behavior: decision}
+		if decision == "deny" {
+			d.Message = msg
+		}
+		response = HookResponse{
+			HookSpecificOutput: HookSpecificOutput{
+				HookEventName: hook.EventPermissionRequest,
+				Decision:      d,
+			},
+		}
+	} else {
+		response = HookResponse{
+			HookSpecificOutput: HookSpecificOutput{
+				HookEventName:      hook.EventPreToolUse,
+				PermissionDecision: decision,
+				Reason:             msg,
+			},
+		}
 	}
 
 	data, _ := json.Marshal(response)
@@ -189,34 +220,3 @@ func respond(allow bool, reason, toolName string, toolInput map[string]interface
 	fmt.Println(string(data))
 	// Exit 0 for success (approval or denial handled via JSON)
 }
-
-func getOperationSummary(toolName string, toolInput map[string]interface{}) string {
-	switch toolName {
-	case "Bash":
-		if cmd, ok := toolInput["command"].(string); ok {
-			if len(cmd) > 60 {
-				cmd = cmd[:57] + "..."
-			}
-			return "Bash: " + cmd
-		}
-	case "Read", "Write", "Edit", "Glob":
-		path := ""
-		if p, ok := toolInput["file_path"].(string); ok {
-			path = p
-		} else if p, ok := toolInput["path"].(string); ok {
-			path = p
-		}
-		if idx := strings.LastIndex(path, "/"); idx >= 0 {
-			path = "..." + path[idx:]
-		}
-		return toolName + ": " + path
-	case "Grep":
-		if pattern, ok := toolInput["pattern"].(string); ok {
-			if len(pattern) > 30 {
-				pattern = pattern[:27] + "..."
-			}
-			return "Grep: " + pattern
-		}
-	}
-	return toolName
-}
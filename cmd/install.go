@@ -9,6 +9,7 @@ import (
 	"strings"
 
 	"github.com/9roads/ccyolo/internal/config"
+	"github.com/9roads/ccyolo/internal/hook"
 	"github.com/9roads/ccyolo/internal/settings"
 	"github.com/spf13/cobra"
 )
@@ -30,10 +31,30 @@ func removeCCYoloDir() error {
 	return os.RemoveAll(ccyoloDir())
 }
 
+var installEvent string
+
+// hookEventNames maps --event values to Claude Code hook events
+var hookEventNames = map[string]string{
+	"pre-tool-use":       hook.EventPreToolUse,
+	"permission-request": hook.EventPermissionRequest,
+}
+
 var installCmd = &cobra.Command{
 	Use:   "install",
 	Short: "Register ccyolo hook with Claude Code",
+	Long: `Register ccyolo hook with Claude Code.
+
+--event selects when Claude Code runs ccyolo:
+  pre-tool-use        before every tool call (default)
+  permission-request  only when Claude Code would ask for permission,
+                      so calls it allows anyway cost nothing`,
 	Run: func(cmd *cobra.Command, args []string) {
+		event, ok := hookEventNames[installEvent]
+		if !ok {
+			fmt.Printf("Invalid event: %s (use pre-tool-use or permission-request)\n", installEvent)
+			return
+		}
+
 		// Create ~/.ccyolo directories
 		if err := createCCYoloDirs(); err != nil {
 			fmt.Printf("Warning: could not create config directory: %v\n", err)
@@ -53,7 +74,7 @@ var installCmd = &cobra.Command{
 
 		hookCmd := binaryPath + " hook"
 
-		err = settings.AddHook(hookCmd)
+		err = settings.AddHook(hookCmd, event)
 		alreadyInstalled := err != nil && err.Error() == "ccyolo hook already installed"
 
 		if err != nil && !alreadyInstalled {
@@ -64,7 +85,7 @@ var installCmd = &cobra.Command{
 		if alreadyInstalled {
 			fmt.Println("ccyolo hook already registered")
 		} else {
-			fmt.Println("ccyolo hook registered with Claude Code")
+			fmt.Printf("ccyolo hook registered with Claude Code (%s)\n", event)
 		}
 		fmt.Println()
 
@@ -117,3 +138,7 @@ var uninstallCmd = &cobra.Command{
 		fmt.Println("\nccyolo uninstalled successfully")
 	},
 }
+
+func init() {
+	installCmd.Flags().StringVar(&installEvent, "event", "pre-tool-use", "Hook event: pre-tool-use or permission-request")
+}
@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/9roads/ccyolo/internal/config"
 	"github.com/9roads/ccyolo/internal/preset"
@@ -113,7 +114,11 @@ This creates ~/.ccyolo/presets/mypreset.json which you can edit.`,
 		}
 
 		// Get base preset
-		basePreset := preset.Get(base)
+		basePreset, err := preset.Get(base)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
 		basePreset.Name = name
 		basePreset.Description = fmt.Sprintf("Custom preset based on %s", base)
 
@@ -138,22 +143,114 @@ var presetShowCmd = &cobra.Command{
 			name = args[0]
 		}
 
-		p := preset.Get(name)
+		p, err := preset.Get(name)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
 		fmt.Printf("Preset: %s\n", p.Name)
-		fmt.Printf("Description: %s\n\n", p.Description)
+		fmt.Printf("Description: %s\n", p.Description)
+		switch p.Secrets {
+		case preset.Allow:
+			fmt.Printf("Secret scan: off\n\n")
+		case preset.Ask:
+			fmt.Printf("Secret scan: ask\n\n")
+		default:
+			fmt.Printf("Secret scan: deny (ask for git-ignored files)\n\n")
+		}
+		t := p.RiskThresholds()
+		fmt.Printf("AI risk score: allow below %d, deny from %d, ask in between\n\n", t.Allow, t.Deny)
+
+		fmt.Println("Rules:")
+		for _, r := range p.AllRules() {
+			fmt.Printf("  %-5s %s: %s", r.Action, r.Tool, r.Pattern)
+			if r.Field != "" {
+				fmt.Printf(" on %s", r.Field)
+			}
+			if n := len(r.All) + len(r.Any); n > 0 {
+				fmt.Printf(" (+%d conditions)", n)
+			}
+			if r.Match != "" {
+				fmt.Printf(" (%s)", r.Match)
+			}
+			if len(r.Except) > 0 {
+				fmt.Printf(" (except %s)", strings.Join(r.Except, ", "))
+			}
+			if r.ID != "" {
+				fmt.Printf(" [%s]", r.ID)
+			}
+			fmt.Println()
+			if r.Reason != "" {
+				fmt.Printf("        %s\n", r.Reason)
+			}
+		}
+
+		for _, r := range p.Rules {
+			if r.Action != "" || r.CacheTTL == 0 {
+				continue
+			}
+			ttl := fmt.Sprintf("%ds", r.CacheTTL)
+			if r.CacheTTL < 0 {
+				ttl = "never"
+			}
+			fmt.Printf("  ttl   %s: %s (%s)", r.Tool, r.Pattern, ttl)
+			if r.ID != "" {
+				fmt.Printf(" [%s]", r.ID)
+			}
+			fmt.Println()
+		}
+
+		if w := p.Web; w != nil {
+			fmt.Println("\nWeb:")
+			fmt.Printf("  default: %s, IP literals: %s, internal hosts: %s, search: %s\n",
+				orNone(w.Default), orNone(w.IPLiteral), orNone(w.Internal), orNone(w.Search))
+			if len(w.Deny) > 0 {
+				fmt.Printf("  deny  %s\n", strings.Join(w.Deny, ", "))
+			}
+			if len(w.Ask) > 0 {
+				fmt.Printf("  ask   %s\n", strings.Join(w.Ask, ", "))
+			}
+			if len(w.Allow) > 0 {
+				fmt.Printf("  allow %s\n", strings.Join(w.Allow, ", "))
+			}
+		}
 
-		fmt.Println("Always Allow:")
-		for _, r := range p.AlwaysAllow {
-			fmt.Printf("  %s: %s\n", r.Tool, r.Pattern)
+		fmt.Println("\nCache normalization:")
+		for _, r := range p.NormalizeRules() {
+			replace := r.Replace
+			if replace == "" {
+				replace = "(never generalize)"
+			}
+			fmt.Printf("  %s -> %s", r.Match, replace)
+			if len(r.Keep) > 0 {
+				fmt.Printf(" (keep %s)", strings.Join(r.Keep, ", "))
+			}
+			if r.ID != "" {
+				fmt.Printf(" [%s]", r.ID)
+			}
+			fmt.Println()
 		}
 
-		fmt.Println("\nAlways Deny:")
-		for _, r := range p.AlwaysDeny {
-			fmt.Printf("  %s: %s\n", r.Tool, r.Pattern)
+		if m := p.MCP; m != nil {
+			fmt.Println("\nMCP:")
+			fmt.Printf("  default: %s, read-only tools: %s\n", orNone(m.Default), orNone(m.ReadOnly))
+			for name, sp := range m.Servers {
+				fmt.Printf("  %s: default %s, read-only %s\n", name, orNone(sp.Default), orNone(sp.ReadOnly))
+				for tool, action := range sp.Tools {
+					fmt.Printf("    %-5s %s\n", action, tool)
+				}
+			}
 		}
 	},
 }
 
+func orNone(a preset.Action) string {
+	if a == "" {
+		return "-"
+	}
+	return string(a)
+}
+
 func init() {
 	presetCmd.AddCommand(presetCreateCmd)
 	presetCmd.AddCommand(presetShowCmd)
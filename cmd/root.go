@@ -3,6 +3,8 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/9roads/ccyolo/internal/config"
 	"github.com/9roads/ccyolo/internal/settings"
@@ -47,7 +49,8 @@ func init() {
 }
 
 func showStatus() {
-	cfg := config.Load()
+	cwd, _ := os.Getwd()
+	cfg := config.LoadFor(cwd)
 
 	fmt.Printf("ccyolo %s\n\n", Version)
 
@@ -65,8 +68,28 @@ func showStatus() {
 	}
 
 	fmt.Printf("Status:  %s\n", status)
+	if event := settings.InstalledEvent(); event != "" {
+		fmt.Printf("Hook:    %s\n", event)
+	}
+	if cfg.ProjectRoot != "" {
+		if cfg.ProjectUntrusted {
+			fmt.Printf("Project: %s (NOT TRUSTED, run 'ccyolo trust')\n", cfg.ProjectRoot)
+		} else {
+			fmt.Printf("Project: %s (trusted)\n", cfg.ProjectRoot)
+			if len(cfg.ProjectIgnored) > 0 {
+				fmt.Printf("         ignoring %s: only preset and cache TTLs can be set per project\n",
+					strings.Join(cfg.ProjectIgnored, ", "))
+			}
+		}
+	}
 	fmt.Printf("Preset:  %s\n", cfg.Preset)
-	fmt.Printf("Model:   %s\n", cfg.Model)
+	if name, pc := cfg.ActiveProvider(); name != "anthropic" || pc.BaseURL != "" {
+		fmt.Printf("LLM:     %s (%s %s)\n", name, pc.Type, pc.BaseURL)
+	}
+	fmt.Printf("Model:   %s\n", cfg.ActiveModel())
+	if esc := cfg.Escalation; esc != nil && esc.Model != "" {
+		fmt.Printf("         escalates to %s\n", esc.Model)
+	}
 
 	// Check API key
 	hasKey := config.HasAPIKey()
@@ -76,7 +99,27 @@ func showStatus() {
 	}
 	fmt.Printf("API Key: %s\n", keyStatus)
 
-	fmt.Printf("Cache:   %ds TTL\n", cfg.CacheTTL)
+	if h := config.LoadHealth(); h.Degraded() {
+		fmt.Printf("API:     DEGRADED since %s (%d failures, last: %s)\n",
+			time.Unix(h.DegradedSince, 0).Format("Jan 2 15:04"), h.Failures, h.LastError)
+		onError := cfg.OnError
+		if onError == "" {
+			onError = "pass to Claude Code"
+		}
+		fmt.Printf("         on error: %s\n", onError)
+		if h.Fallbacks > 0 {
+			fmt.Printf("         %d call(s) decided by it, last: %s at %s\n", h.Fallbacks, h.LastFallback,
+				time.Unix(h.LastFallbackAt, 0).Format("Jan 2 15:04"))
+		}
+	} else {
+		fmt.Println("API:     ok")
+	}
+
+	askTTL := "never cached"
+	if ttl := cfg.AskTTL(); ttl > 0 {
+		askTTL = fmt.Sprintf("%ds", ttl)
+	}
+	fmt.Printf("Cache:   allows %ds, asks %s\n", cfg.CacheTTL, askTTL)
 
 	logStatus := "disabled"
 	if cfg.Logging {
@@ -0,0 +1,74 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/9roads/ccyolo/internal/config"
+	"github.com/spf13/cobra"
+)
+
+var trustCmd = &cobra.Command{
+	Use:   "trust [dir]",
+	Short: "Trust the project's ccyolo config",
+	Long: `Trust the .ccyolo.json and .ccyolo/presets/ found by walking up from dir
+(default: current directory).
+
+Project configs are ignored until trusted, and are distrusted again
+whenever any of their files change. Review them before trusting: a
+project config can loosen the policy applied to that repository.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		root := findProjectArg(args)
+		if root == "" {
+			return
+		}
+
+		fmt.Printf("Project: %s\n", root)
+		for _, f := range config.ProjectFiles(root) {
+			fmt.Printf("  %s\n", f)
+		}
+
+		if err := config.Trust(root); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		fmt.Println("\nProject config trusted")
+	},
+}
+
+var untrustCmd = &cobra.Command{
+	Use:   "untrust [dir]",
+	Short: "Stop applying the project's ccyolo config",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		root := findProjectArg(args)
+		if root == "" {
+			return
+		}
+
+		if err := config.Untrust(root); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		fmt.Printf("Project config no longer trusted: %s\n", root)
+	},
+}
+
+func findProjectArg(args []string) string {
+	dir, _ := os.Getwd()
+	if len(args) > 0 {
+		dir = args[0]
+	}
+
+	root := config.FindProject(dir)
+	if root == "" {
+		fmt.Printf("No %s or %s/presets/ found from %s\n", config.ProjectFile, config.ProjectDir, dir)
+	}
+	return root
+}
+
+func init() {
+	rootCmd.AddCommand(trustCmd)
+	rootCmd.AddCommand(untrustCmd)
+}
@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/9roads/ccyolo/internal/usage"
+	"github.com/spf13/cobra"
+)
+
+var (
+	usageDays int
+	usageBy   string
+)
+
+var usageCmd = &cobra.Command{
+	Use:   "usage",
+	Short: "Show API token usage and estimated cost",
+	Long: `Show the tokens used by AI evaluations and their estimated cost.
+
+Calls are grouped by day, preset and model unless --by says otherwise, e.g.
+--by model or --by day,tier. Costs are estimates from list prices; models
+without a known price (such as local ones) count tokens only.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		by := strings.Split(usageBy, ",")
+		for i, field := range by {
+			by[i] = strings.TrimSpace(field)
+			switch by[i] {
+			case "day", "preset", "model", "tier":
+			default:
+				fmt.Printf("Error: can't group by %q (want day, preset, model or tier)\n", by[i])
+				os.Exit(1)
+			}
+		}
+
+		since := time.Now().AddDate(0, 0, -usageDays)
+		records, err := usage.Load(since)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		if len(records) == 0 {
+			fmt.Printf("No API calls in the last %d days\n", usageDays)
+			return
+		}
+
+		totals, all := usage.Group(records, by)
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintf(w, "%s\tCALLS\tINPUT\tOUTPUT\tCACHE WRITE\tCACHE READ\tCOST\n", strings.ToUpper(strings.Join(by, "\t")))
+		for _, t := range totals {
+			printTotal(w, strings.Join(t.Key, "\t"), t)
+		}
+		printTotal(w, "total"+strings.Repeat("\t", len(by)-1), all)
+		w.Flush()
+	},
+}
+
+func printTotal(w *tabwriter.Writer, key string, t usage.Total) {
+	cost := fmt.Sprintf("$%.4f", t.Cost)
+	if t.Unpriced == t.Calls {
+		cost = "-"
+	} else if t.Unpriced > 0 {
+		cost += "+"
+	}
+	fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%d\t%d\t%s\n", key, t.Calls, t.Input, t.Output, t.CacheWrite, t.CacheRead, cost)
+}
+
+var usageClearCmd = &cobra.Command{
+	Use:   "clear",
+	Short: "Delete recorded usage",
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := usage.Clear(); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		fmt.Println("Usage cleared")
+	},
+}
+
+func init() {
+	usageCmd.Flags().IntVar(&usageDays, "days", 30, "Only show the last N days")
+	usageCmd.Flags().StringVar(&usageBy, "by", "day,preset,model", "Group by day, preset, model and/or tier")
+	usageCmd.AddCommand(usageClearCmd)
+	rootCmd.AddCommand(usageCmd)
+}
@@ -5,103 +5,172 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"os"
-	"path/filepath"
-	"regexp"
+	"strings"
 	"time"
 
 	"github.com/9roads/ccyolo/internal/config"
+	"github.com/9roads/ccyolo/internal/hook"
+	"github.com/9roads/ccyolo/internal/secrets"
+	"github.com/9roads/ccyolo/internal/tools"
 )
 
 type Entry struct {
-	Approve   bool  `json:"approve"`
-	Timestamp int64 `json:"timestamp"`
+	Approve      bool   `json:"approve"`
+	Deny         bool   `json:"deny,omitempty"` // a denial; neither set: ask
+	RiskScore    int    `json:"risk_score,omitempty"`
+	RiskCategory string `json:"risk_category,omitempty"`
+	Timestamp    int64  `json:"timestamp"`
+	TTL          int    `json:"ttl,omitempty"` // seconds; entries of older versions use cache_ttl
+
+	// What the decision was for, for listing and revoking entries
+	Tool    string `json:"tool,omitempty"`
+	Subject string `json:"subject,omitempty"` // command as cached, path or URL; credentials redacted
+	Reason  string `json:"reason,omitempty"`
+	Source  string `json:"source,omitempty"` // evaluator that decided
+
+	// What the decision was made under
+	Scope
 }
 
-func getCacheKey(toolName string, toolInput map[string]interface{}, preset string) string {
+// Scope is what a cached decision depends on besides the call itself.
+type Scope struct {
+	Preset      string `json:"preset,omitempty"`
+	Fingerprint string `json:"fingerprint,omitempty"` // of the preset's policy and the models
+	Project     string `json:"project,omitempty"`     // project root, when scoped to projects
+
+	// Normalize generalizes Bash commands, so calls that differ only in
+	// their arguments share an entry. Without it commands are cached
+	// verbatim.
+	Normalize func(cmd, cwd string) string `json:"-"`
+}
+
+func (s Scope) command(cmd, cwd string) string {
+	if s.Normalize == nil {
+		return cmd
+	}
+	return s.Normalize(cmd, cwd)
+}
+
+func getCacheKey(in hook.Input, scope Scope) string {
+	toolName, toolInput := in.ToolName, in.ToolInput
+
 	// Normalize input for caching
 	var normalized string
 
 	if toolName == "Bash" {
 		if cmd, ok := toolInput["command"].(string); ok {
-			normalized = normalizeCommand(cmd)
+			normalized = scope.command(cmd, in.Cwd)
 		}
 	} else {
 		data, _ := json.Marshal(toolInput)
 		normalized = string(data)
 	}
 
-	input := preset + ":" + toolName + ":" + normalized
+	// The LLM sees the permission mode, so its decisions depend on it
+	input := scope.Preset + ":" + scope.Fingerprint + ":" + scope.Project + ":" +
+		in.PermissionMode + ":" + toolName + ":" + normalized
 	hash := sha256.Sum256([]byte(input))
 	return hex.EncodeToString(hash[:8])
 }
 
-func normalizeCommand(cmd string) string {
-	patterns := []struct {
-		regex   string
-		replace string
-	}{
-		{`^(npm|yarn|pnpm)\s+(install|add|remove)\s+.+`, "$1 $2 *"},
-		{`^pip3?\s+install\s+.+`, "pip install *"},
-		{`^git\s+commit\s+.+`, "git commit *"},
-		{`^rm\s+[^-].*`, "rm *"},      // rm without flags
-		{`^rm\s+-[^r].*`, "rm -* *"},  // rm with flags but not -r
-		{`^mkdir\s+.+`, "mkdir *"},
-		{`^(cat|head|tail)\s+.+`, "$1 *"},
-	}
-
-	for _, p := range patterns {
-		re := regexp.MustCompile(p.regex)
-		if re.MatchString(cmd) {
-			return re.ReplaceAllString(cmd, p.replace)
+// maxSubject bounds the subject stored with an entry.
+const maxSubject = 500
+
+// subject describes what a cached decision covers: the command as
+// generalized for the key, or the path or URL.
+func subject(in hook.Input, scope Scope) string {
+	var s string
+	if t, ok := tools.Lookup(in.ToolName); ok {
+		switch {
+		case t.Command != "":
+			cmd, _ := in.ToolInput[t.Command].(string)
+			s = scope.command(cmd, in.Cwd)
+		case t.URL != "":
+			s, _ = in.ToolInput[t.URL].(string)
+		default:
+			s, _ = t.Path(in.ToolInput, in.Cwd)
 		}
 	}
-
-	return cmd
+	if s == "" {
+		data, _ := json.Marshal(in.ToolInput)
+		s = string(data)
+	}
+	s = secrets.RedactText(s)
+	if len(s) > maxSubject {
+		s = strings.ToValidUTF8(s[:maxSubject], "") + "..."
+	}
+	return s
 }
 
-func Get(toolName string, toolInput map[string]interface{}, preset string) *bool {
-	cfg := config.Load()
-	key := getCacheKey(toolName, toolInput, preset)
-	cacheFile := filepath.Join(config.CacheDir(), key+".json")
+// staleTTL is how long entries are kept past their TTL, for the
+// stale-cache fallback when the API is unavailable.
+const staleTTL = 7 * 24 * 60 * 60
 
-	data, err := os.ReadFile(cacheFile)
-	if err != nil {
-		return nil
+// Lifetime returns how long an entry stays fresh, in seconds: its own TTL,
+// or defaultTTL for entries cached without one.
+func (e Entry) Lifetime(defaultTTL int) int64 {
+	if e.TTL > 0 {
+		return int64(e.TTL)
 	}
+	return int64(defaultTTL)
+}
 
-	var entry Entry
-	if err := json.Unmarshal(data, &entry); err != nil {
-		return nil
-	}
+// Expired reports whether an entry has outlived its TTL.
+func (e Entry) Expired(defaultTTL int) bool {
+	return time.Now().Unix()-e.Timestamp > e.Lifetime(defaultTTL)
+}
 
-	// Check expiration
-	if time.Now().Unix()-entry.Timestamp > int64(cfg.CacheTTL) {
-		os.Remove(cacheFile)
+// Get returns the decision cached for a call if it hasn't expired.
+// defaultTTL applies to entries cached without a TTL.
+func Get(in hook.Input, scope Scope, defaultTTL int) *Entry {
+	key := getCacheKey(in, scope)
+	it := lookup(key, defaultTTL)
+	if it == nil || it.Expired(defaultTTL) {
 		return nil
 	}
-
-	return &entry.Approve
+	if time.Now().Unix()-it.Used >= touchInterval {
+		touch(key)
+	}
+	return &it.Entry
 }
 
-func Set(toolName string, toolInput map[string]interface{}, preset string, approve bool) {
-	key := getCacheKey(toolName, toolInput, preset)
+// touchInterval is how often a hit is recorded for LRU eviction. Hits in
+// between don't write, so busy entries don't grow the store.
+const touchInterval = 10 * 60
 
-	if err := os.MkdirAll(config.CacheDir(), 0755); err != nil {
-		return
+// GetStale returns a decision even if it has expired.
+func GetStale(in hook.Input, scope Scope, defaultTTL int) *Entry {
+	if it := lookup(getCacheKey(in, scope), defaultTTL); it != nil {
+		return &it.Entry
 	}
+	return nil
+}
 
-	entry := Entry{
-		Approve:   approve,
-		Timestamp: time.Now().Unix(),
+// lookup reads an entry. Entries past the stale window are left for
+// compaction to remove.
+func lookup(key string, defaultTTL int) *Item {
+	it := find(key)
+	if it == nil || it.pastStale(defaultTTL) {
+		return nil
 	}
+	return it
+}
 
-	data, err := json.Marshal(entry)
-	if err != nil {
-		return
-	}
+// pastStale reports whether an entry is too old even for the stale-cache
+// fallback.
+func (e Entry) pastStale(defaultTTL int) bool {
+	return time.Now().Unix()-e.Timestamp > e.Lifetime(defaultTTL)+staleTTL
+}
 
-	cacheFile := filepath.Join(config.CacheDir(), key+".json")
-	os.WriteFile(cacheFile, data, 0644)
+// Set caches a decision for entry.TTL seconds. Entries made under another
+// fingerprint are unreachable and left to expire or be evicted by Compact.
+func Set(in hook.Input, scope Scope, entry Entry) {
+	entry.Timestamp = time.Now().Unix()
+	entry.Scope = scope
+	entry.Tool, entry.Subject = in.ToolName, subject(in, scope)
+	if appendRecords(record{Key: getCacheKey(in, scope), Entry: &entry}) == nil && setRecords >= 0 {
+		setRecords++
+	}
 }
 
 func Clear() error {
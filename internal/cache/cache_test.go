@@ -0,0 +1,71 @@
+package cache
+
+import (
+	"os"
+	"testing"
+
+	"github.com/9roads/ccyolo/internal/config"
+	"github.com/9roads/ccyolo/internal/hook"
+)
+
+func bash(command string) hook.Input {
+	return hook.Input{ToolName: "Bash", ToolInput: map[string]interface{}{"command": command}}
+}
+
+func TestSetKeepsOtherFingerprints(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	old := Scope{Preset: "balanced", Fingerprint: "a"}
+	cur := Scope{Preset: "balanced", Fingerprint: "b"}
+
+	Set(bash("ls"), old, Entry{Approve: true, TTL: 3600})
+	Set(bash("pwd"), cur, Entry{Approve: true, TTL: 3600})
+
+	if Get(bash("ls"), cur, 3600) != nil {
+		t.Error("entry of another fingerprint served")
+	}
+	if Get(bash("ls"), old, 3600) == nil {
+		t.Error("entry of another fingerprint deleted by Set")
+	}
+	if Get(bash("pwd"), cur, 3600) == nil {
+		t.Error("entry not cached")
+	}
+}
+
+func TestGetTouchesOnce(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	scope := Scope{Preset: "balanced"}
+	Set(bash("ls"), scope, Entry{Approve: true, TTL: 3600})
+	before, _ := os.ReadFile(Path())
+	for i := 0; i < 5; i++ {
+		if Get(bash("ls"), scope, 3600) == nil {
+			t.Fatal("entry not served")
+		}
+	}
+	if after, _ := os.ReadFile(Path()); len(after) != len(before) {
+		t.Errorf("hits right after Set wrote %d bytes", len(after)-len(before))
+	}
+}
+
+func TestNeedsCompaction(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	cfg := config.Config{CacheMaxEntries: 3}
+	for _, cmd := range []string{"a", "b", "c"} {
+		Set(bash(cmd), Scope{}, Entry{Approve: true, TTL: 3600})
+	}
+	// Entries are counted by the lookup's read, as in the hook
+	setRecords = -1
+	Get(bash("a"), Scope{}, 3600)
+	if NeedsCompaction(cfg) {
+		t.Error("3 entries: want no compaction")
+	}
+	Set(bash("d"), Scope{}, Entry{Approve: true, TTL: 3600})
+	if !NeedsCompaction(cfg) {
+		t.Error("4 entries: want compaction")
+	}
+	if _, _, err := Compact(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if NeedsCompaction(cfg) {
+		t.Error("after Compact: want no compaction")
+	}
+}
@@ -0,0 +1,35 @@
+//go:build !unix
+
+package cache
+
+import (
+	"os"
+	"time"
+)
+
+// Without flock, lock creates path exclusively and removes it on release.
+// Every lock is exclusive, and one left behind by a crashed process is
+// broken after staleLock.
+const staleLock = 10 * time.Second
+
+func lock(path string, exclusive bool) (func(), error) {
+	deadline := time.Now().Add(2 * staleLock)
+	for {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if err == nil {
+			f.Close()
+			return func() { os.Remove(path) }, nil
+		}
+		if !os.IsExist(err) {
+			return nil, err
+		}
+		if info, err := os.Stat(path); err == nil && time.Since(info.ModTime()) > staleLock {
+			os.Remove(path)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, err
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,297 @@
+package cache
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/9roads/ccyolo/internal/config"
+)
+
+// The store is one JSON lines file. Each line sets, touches or deletes a
+// key and the last line for a key wins, so writers only ever append: a
+// line is a single write under a shared lock, and concurrent hooks can't
+// corrupt each other. Compaction rewrites the file with just the live
+// entries, under an exclusive lock, and evicts the least recently used
+// ones beyond the size limits.
+
+// Defaults for cache_max_entries and cache_max_bytes
+const (
+	defaultMaxEntries = 10000
+	defaultMaxBytes   = 4 << 20
+)
+
+type record struct {
+	Key     string `json:"k"`
+	Entry   *Entry `json:"e,omitempty"`
+	Used    int64  `json:"u,omitempty"` // touched: last used
+	Deleted bool   `json:"d,omitempty"`
+}
+
+// Item is an entry as read from the store.
+type Item struct {
+	Key string `json:"key"`
+	Entry
+	Used int64 `json:"used"` // last set or served
+}
+
+// Path is the store file.
+func Path() string {
+	return filepath.Join(config.CacheDir(), "decisions.jsonl")
+}
+
+func lockPath() string {
+	return filepath.Join(config.CacheDir(), "decisions.lock")
+}
+
+// apply folds a record into the items read so far.
+func apply(items map[string]*Item, r record) {
+	switch {
+	case r.Deleted:
+		delete(items, r.Key)
+	case r.Entry != nil:
+		items[r.Key] = &Item{Entry: *r.Entry, Key: r.Key, Used: r.Entry.Timestamp}
+	case r.Used > 0:
+		if it := items[r.Key]; it != nil && r.Used > it.Used {
+			it.Used = r.Used
+		}
+	}
+}
+
+// setRecords is the number of records setting an entry found by the last
+// read of the store, for NeedsCompaction; -1 before the first read. It
+// overcounts keys set more than once.
+var setRecords = -1
+
+// setMarker starts an entry in a record. Quotes inside JSON strings are
+// escaped, so it can't occur in a value.
+var setMarker = []byte(`"e":{`)
+
+// read folds the store's records. With key set, only that key's lines are
+// decoded. A torn last line from a writer in progress is skipped.
+func read(key string) (map[string]*Item, error) {
+	f, err := os.Open(Path())
+	if err != nil {
+		if os.IsNotExist(err) {
+			setRecords = 0
+			return map[string]*Item{}, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	var needle []byte
+	if key != "" {
+		needle = []byte(`"k":"` + key + `"`)
+	}
+	items := make(map[string]*Item)
+	sets := 0
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if bytes.Contains(line, setMarker) {
+			sets++
+		}
+		if needle != nil && !bytes.Contains(line, needle) {
+			continue
+		}
+		var r record
+		if json.Unmarshal(line, &r) == nil && r.Key != "" {
+			apply(items, r)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return items, err
+	}
+	setRecords = sets
+	return items, nil
+}
+
+// find returns a key's entry, or nil.
+func find(key string) *Item {
+	items, err := read(key)
+	if err != nil {
+		return nil
+	}
+	return items[key]
+}
+
+// List returns the cached entries, most recently used first.
+func List() ([]Item, error) {
+	items, err := read("")
+	if err != nil {
+		return nil, err
+	}
+	list := make([]Item, 0, len(items))
+	for _, it := range items {
+		list = append(list, *it)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Used > list[j].Used })
+	return list, nil
+}
+
+// Delete removes entries by key.
+func Delete(keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	records := make([]record, len(keys))
+	for i, key := range keys {
+		records[i] = record{Key: key, Deleted: true}
+	}
+	return appendRecords(records...)
+}
+
+// touch marks a key as used, for LRU eviction.
+func touch(key string) {
+	appendRecords(record{Key: key, Used: time.Now().Unix()})
+}
+
+// appendRecords writes records in one append.
+func appendRecords(records ...record) error {
+	var buf bytes.Buffer
+	for _, r := range records {
+		data, err := json.Marshal(r)
+		if err != nil {
+			return err
+		}
+		buf.Write(data)
+		buf.WriteByte('\n')
+	}
+
+	if err := os.MkdirAll(config.CacheDir(), 0755); err != nil {
+		return err
+	}
+	unlock, err := lock(lockPath(), false)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	f, err := os.OpenFile(Path(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(buf.Bytes()); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func limits(cfg config.Config) (int, int64) {
+	maxEntries, maxBytes := cfg.CacheMaxEntries, int64(cfg.CacheMaxBytes)
+	if maxEntries <= 0 {
+		maxEntries = defaultMaxEntries
+	}
+	if maxBytes <= 0 {
+		maxBytes = defaultMaxBytes
+	}
+	return maxEntries, maxBytes
+}
+
+// NeedsCompaction reports whether the store has outgrown cache_max_bytes,
+// or cache_max_entries as counted by the last read in this process, such
+// as the cache lookup. It only stats the file.
+func NeedsCompaction(cfg config.Config) bool {
+	maxEntries, maxBytes := limits(cfg)
+	info, err := os.Stat(Path())
+	return err == nil && (info.Size() > maxBytes || setRecords > maxEntries)
+}
+
+// Compact rewrites the store with only live entries: those past the stale
+// window are dropped, and the least recently used are evicted until the
+// store is within cache_max_entries and half of cache_max_bytes, so it
+// doesn't need compacting again right away. It returns the number of
+// entries kept and removed.
+func Compact(cfg config.Config) (int, int, error) {
+	if err := os.MkdirAll(config.CacheDir(), 0755); err != nil {
+		return 0, 0, err
+	}
+	unlock, err := lock(lockPath(), true)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer unlock()
+
+	items, err := read("")
+	if err != nil {
+		return 0, 0, err
+	}
+	all := make([]*Item, 0, len(items))
+	for _, it := range items {
+		all = append(all, it)
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].Used > all[j].Used })
+
+	maxEntries, maxBytes := limits(cfg)
+	var buf bytes.Buffer
+	kept := 0
+	for _, it := range all {
+		if it.pastStale(cfg.CacheTTL) || kept >= maxEntries {
+			continue
+		}
+		entry := it.Entry
+		data, err := json.Marshal(record{Key: it.Key, Entry: &entry})
+		if err != nil {
+			continue
+		}
+		if int64(buf.Len()+len(data)) > maxBytes/2 {
+			break
+		}
+		buf.Write(data)
+		buf.WriteByte('\n')
+		if it.Used > it.Timestamp {
+			data, _ = json.Marshal(record{Key: it.Key, Used: it.Used})
+			buf.Write(data)
+			buf.WriteByte('\n')
+		}
+		kept++
+	}
+
+	if err := writeAtomic(Path(), buf.Bytes()); err != nil {
+		return 0, 0, err
+	}
+	removeLegacy()
+	setRecords = kept
+	return kept, len(all) - kept, nil
+}
+
+// writeAtomic replaces path with data through a rename, so readers see
+// either the old file or the new one.
+func writeAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if err := tmp.Chmod(0644); err != nil {
+		tmp.Close()
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// removeLegacy deletes the one-file-per-key entries of older versions.
+func removeLegacy() {
+	files, _ := filepath.Glob(filepath.Join(config.CacheDir(), "*.json"))
+	for _, f := range files {
+		os.Remove(f)
+	}
+}
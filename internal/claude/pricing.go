@@ -0,0 +1,54 @@
+package claude
+
+import "strings"
+
+// Usage is the tokens a completion consumed. InputTokens excludes the
+// cached prompt prefix, counted in CacheWriteTokens or CacheReadTokens.
+type Usage struct {
+	InputTokens      int
+	OutputTokens     int
+	CacheWriteTokens int
+	CacheReadTokens  int
+}
+
+// Cache pricing relative to uncached input
+const (
+	cacheWriteFactor = 1.25
+	cacheReadFactor  = 0.1
+)
+
+// price is the cost of a model in dollars per million tokens.
+type price struct {
+	input, output float64
+}
+
+// prices of known models by name prefix; more specific prefixes first.
+var prices = []struct {
+	prefix string
+	price
+}{
+	{"claude-haiku-4", price{1, 5}},
+	{"claude-3-5-haiku", price{0.8, 4}},
+	{"claude-3-haiku", price{0.25, 1.25}},
+	{"claude-sonnet-4", price{3, 15}},
+	{"claude-3-7-sonnet", price{3, 15}},
+	{"claude-3-5-sonnet", price{3, 15}},
+	{"claude-opus-4-5", price{5, 25}},
+	{"claude-opus-4", price{15, 75}},
+	{"gpt-4o-mini", price{0.15, 0.6}},
+	{"gpt-4o", price{2.5, 10}},
+}
+
+// Cost estimates what the usage cost in dollars on model, and returns false
+// for models without a known price (such as local ones).
+func (u Usage) Cost(model string) (float64, bool) {
+	for _, p := range prices {
+		if strings.HasPrefix(model, p.prefix) {
+			input := float64(u.InputTokens) +
+				float64(u.CacheWriteTokens)*cacheWriteFactor +
+				float64(u.CacheReadTokens)*cacheReadFactor
+			return (input*p.input + float64(u.OutputTokens)*p.output) / 1e6, true
+		}
+	}
+	return 0, false
+}
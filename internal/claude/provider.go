@@ -0,0 +1,460 @@
+package claude
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/9roads/ccyolo/internal/config"
+)
+
+// ErrNoAPIKey is returned when the provider needs a key and none is set.
+var ErrNoAPIKey = errors.New("no API key configured")
+
+// Completion is a single-turn request to a model. With Tool set the model
+// must answer by calling that tool.
+type Completion struct {
+	Model     string
+	System    string
+	Prompt    string
+	MaxTokens int
+	Tool      *Tool
+}
+
+// Tool is a function the model is forced to call, with a JSON schema for
+// its input.
+type Tool struct {
+	Name        string
+	Description string
+	Schema      map[string]interface{}
+}
+
+// Reply is a model's answer: the text, or the tool input when the
+// completion forced a tool.
+type Reply struct {
+	Text      string
+	ToolInput json.RawMessage
+	Usage     Usage
+}
+
+// Provider sends completions to an LLM backend.
+type Provider interface {
+	Name() string
+	Complete(ctx context.Context, c Completion) (Reply, error)
+}
+
+// NewProvider builds the backend selected in the config and returns it with
+// the model to use.
+func NewProvider(cfg config.Config) (Provider, string, error) {
+	name, pc := cfg.ActiveProvider()
+	model := cfg.ActiveModel()
+
+	client, err := httpClient(pc.CABundle)
+	if err != nil {
+		return nil, "", fmt.Errorf("provider %s: %w", name, err)
+	}
+	base := httpProvider{name: name, client: client, baseURL: strings.TrimSuffix(pc.BaseURL, "/"), headers: pc.Headers}
+
+	apiKey := ""
+	if pc.APIKeyEnv != "" {
+		apiKey = os.Getenv(pc.APIKeyEnv)
+	}
+
+	switch pc.Type {
+	case "anthropic":
+		if pc.APIKeyEnv == "" {
+			apiKey = config.GetAPIKey()
+		}
+		if apiKey == "" {
+			return nil, "", ErrNoAPIKey
+		}
+		if base.baseURL == "" {
+			base.baseURL = "https://api.anthropic.com"
+		}
+		return &anthropic{httpProvider: base, apiKey: apiKey}, model, nil
+
+	case "openai":
+		if apiKey == "" && pc.APIKeyEnv == "" {
+			apiKey = os.Getenv("OPENAI_API_KEY")
+		}
+		if base.baseURL == "" {
+			if apiKey == "" {
+				return nil, "", ErrNoAPIKey
+			}
+			base.baseURL = "https://api.openai.com/v1"
+		}
+		return &openAI{httpProvider: base, apiKey: apiKey}, model, nil
+
+	case "ollama":
+		if base.baseURL == "" {
+			base.baseURL = "http://localhost:11434"
+		}
+		// Local models are slower, especially on first load
+		client.Timeout = 60 * time.Second
+		return &ollama{httpProvider: base}, model, nil
+	}
+	return nil, "", fmt.Errorf("provider %s: unknown type %q", name, pc.Type)
+}
+
+// ValidateProvider sends a minimal completion to the configured provider
+// with each model in use: the active model and the escalation model.
+func ValidateProvider(cfg config.Config) error {
+	p, model, err := NewProvider(cfg)
+	if err != nil {
+		return err
+	}
+	models := []string{model}
+	if esc := cfg.Escalation; esc != nil && esc.Model != "" && esc.Model != model {
+		models = append(models, esc.Model)
+	}
+	for _, m := range models {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		_, err := p.Complete(ctx, Completion{Model: m, Prompt: "hi", MaxTokens: 16})
+		cancel()
+		if err != nil {
+			return fmt.Errorf("%s: %w", m, err)
+		}
+	}
+	return nil
+}
+
+// httpClient trusts the system roots plus an optional PEM bundle, for
+// gateways behind a corporate CA.
+func httpClient(caBundle string) (*http.Client, error) {
+	client := &http.Client{Timeout: 10 * time.Second}
+	if caBundle == "" {
+		return client, nil
+	}
+
+	pem, err := os.ReadFile(caBundle)
+	if err != nil {
+		return nil, err
+	}
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates in %s", caBundle)
+	}
+	client.Transport = &http.Transport{
+		Proxy:           http.ProxyFromEnvironment,
+		TLSClientConfig: &tls.Config{RootCAs: pool},
+	}
+	return client, nil
+}
+
+type httpProvider struct {
+	name    string
+	client  *http.Client
+	baseURL string
+	headers map[string]string
+}
+
+func (p httpProvider) Name() string { return p.name }
+
+// Retries of overloaded or failing backends: up to maxAttempts, with
+// jittered exponential backoff, as long as the context's deadline allows.
+const (
+	maxAttempts = 4
+	baseBackoff = 500 * time.Millisecond
+)
+
+// retryable reports whether a status is worth another attempt: rate limits,
+// server errors and Anthropic's 529 overloaded.
+func retryable(status int) bool {
+	return status == http.StatusTooManyRequests || status >= 500
+}
+
+// backoff returns the wait before attempt n (1-based), honoring a
+// Retry-After header in seconds.
+func backoff(n int, retryAfter string) time.Duration {
+	if secs, err := strconv.Atoi(retryAfter); err == nil && secs > 0 {
+		return time.Duration(secs) * time.Second
+	}
+	d := baseBackoff << (n - 1)
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+// post sends a JSON body and decodes the JSON reply into out, retrying
+// transient failures. errMsg extracts the backend's error message from a
+// failed reply.
+func (p httpProvider) post(ctx context.Context, path string, headers map[string]string, body, out interface{}, errMsg func([]byte) string) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	for attempt := 1; ; attempt++ {
+		status, retryAfter, respBody, err := p.send(ctx, path, headers, data)
+		if err != nil {
+			return err
+		}
+		if status < 400 {
+			return json.Unmarshal(respBody, out)
+		}
+
+		err = fmt.Errorf("API error (status %d)", status)
+		if msg := errMsg(respBody); msg != "" {
+			err = fmt.Errorf("API error (status %d): %s", status, msg)
+		}
+		if !retryable(status) || attempt == maxAttempts {
+			return err
+		}
+
+		wait := backoff(attempt, retryAfter)
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < wait {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(wait):
+		}
+	}
+}
+
+func (p httpProvider) send(ctx context.Context, path string, headers map[string]string, data []byte) (int, string, []byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", p.baseURL+path, bytes.NewReader(data))
+	if err != nil {
+		return 0, "", nil, err
+	}
+	req.Header.Set("content-type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	for k, v := range p.headers {
+		req.Header.Set(k, os.ExpandEnv(v))
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return 0, "", nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, "", nil, err
+	}
+	return resp.StatusCode, resp.Header.Get("retry-after"), body, nil
+}
+
+// anthropic talks to the Messages API, directly or through a gateway.
+type anthropic struct {
+	httpProvider
+	apiKey string
+}
+
+func (p *anthropic) Complete(ctx context.Context, c Completion) (Reply, error) {
+	reqBody := Request{
+		Model:     c.Model,
+		MaxTokens: c.MaxTokens,
+		Messages:  []Message{{Role: "user", Content: c.Prompt}},
+	}
+	if c.System != "" {
+		reqBody.System = []SystemBlock{{Type: "text", Text: c.System, CacheControl: &CacheControl{Type: "ephemeral"}}}
+	}
+	if c.Tool != nil {
+		reqBody.Tools = []ToolDef{{Name: c.Tool.Name, Description: c.Tool.Description, InputSchema: c.Tool.Schema}}
+		reqBody.ToolChoice = &ToolChoice{Type: "tool", Name: c.Tool.Name}
+	}
+	headers := map[string]string{"x-api-key": p.apiKey, "anthropic-version": "2023-06-01"}
+
+	var response Response
+	err := p.post(ctx, "/v1/messages", headers, reqBody, &response, func(body []byte) string {
+		var r Response
+		if json.Unmarshal(body, &r) == nil && r.Error != nil {
+			return r.Error.Message
+		}
+		return ""
+	})
+	if err != nil {
+		return Reply{}, err
+	}
+	if response.Error != nil {
+		return Reply{}, fmt.Errorf("API error: %s", response.Error.Message)
+	}
+
+	reply := Reply{Usage: Usage{
+		InputTokens:      response.Usage.InputTokens,
+		OutputTokens:     response.Usage.OutputTokens,
+		CacheWriteTokens: response.Usage.CacheCreationInputTokens,
+		CacheReadTokens:  response.Usage.CacheReadInputTokens,
+	}}
+	for _, block := range response.Content {
+		switch block.Type {
+		case "text":
+			reply.Text += block.Text
+		case "tool_use":
+			if c.Tool != nil && block.Name == c.Tool.Name {
+				reply.ToolInput = block.Input
+			}
+		}
+	}
+	if reply.Text == "" && reply.ToolInput == nil {
+		return Reply{}, fmt.Errorf("empty response")
+	}
+	return reply, nil
+}
+
+type chatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+func chatMessages(c Completion) []chatMessage {
+	var msgs []chatMessage
+	if c.System != "" {
+		msgs = append(msgs, chatMessage{Role: "system", Content: c.System})
+	}
+	return append(msgs, chatMessage{Role: "user", Content: c.Prompt})
+}
+
+// openAI talks to an OpenAI-compatible /chat/completions endpoint.
+type openAI struct {
+	httpProvider
+	apiKey string
+}
+
+type openAIError struct {
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func (p *openAI) Complete(ctx context.Context, c Completion) (Reply, error) {
+	reqBody := map[string]interface{}{
+		"model":      c.Model,
+		"max_tokens": c.MaxTokens,
+		"messages":   chatMessages(c),
+	}
+	if c.Tool != nil {
+		reqBody["tools"] = []interface{}{map[string]interface{}{
+			"type": "function",
+			"function": map[string]interface{}{
+				"name":        c.Tool.Name,
+				"description": c.Tool.Description,
+				"parameters":  c.Tool.Schema,
+				"strict":      true,
+			},
+		}}
+		reqBody["tool_choice"] = map[string]interface{}{
+			"type":     "function",
+			"function": map[string]string{"name": c.Tool.Name},
+		}
+	}
+	headers := map[string]string{}
+	if p.apiKey != "" {
+		headers["authorization"] = "Bearer " + p.apiKey
+	}
+
+	var response struct {
+		openAIError
+		Choices []struct {
+			Message struct {
+				Content   string `json:"content"`
+				ToolCalls []struct {
+					Function struct {
+						Name      string `json:"name"`
+						Arguments string `json:"arguments"`
+					} `json:"function"`
+				} `json:"tool_calls"`
+			} `json:"message"`
+		} `json:"choices"`
+		Usage struct {
+			PromptTokens        int `json:"prompt_tokens"`
+			CompletionTokens    int `json:"completion_tokens"`
+			PromptTokensDetails struct {
+				CachedTokens int `json:"cached_tokens"`
+			} `json:"prompt_tokens_details"`
+		} `json:"usage"`
+	}
+	err := p.post(ctx, "/chat/completions", headers, reqBody, &response, func(body []byte) string {
+		var r openAIError
+		if json.Unmarshal(body, &r) == nil && r.Error != nil {
+			return r.Error.Message
+		}
+		return ""
+	})
+	if err != nil {
+		return Reply{}, err
+	}
+	if response.Error != nil {
+		return Reply{}, fmt.Errorf("API error: %s", response.Error.Message)
+	}
+	if len(response.Choices) == 0 {
+		return Reply{}, fmt.Errorf("empty response")
+	}
+
+	msg := response.Choices[0].Message
+	// OpenAI caches prompt prefixes on its own and counts them in prompt_tokens
+	cached := response.Usage.PromptTokensDetails.CachedTokens
+	reply := Reply{Text: msg.Content, Usage: Usage{
+		InputTokens:     response.Usage.PromptTokens - cached,
+		OutputTokens:    response.Usage.CompletionTokens,
+		CacheReadTokens: cached,
+	}}
+	for _, call := range msg.ToolCalls {
+		if c.Tool != nil && call.Function.Name == c.Tool.Name {
+			reply.ToolInput = json.RawMessage(call.Function.Arguments)
+		}
+	}
+	return reply, nil
+}
+
+// ollama talks to a local Ollama server's /api/chat.
+type ollama struct {
+	httpProvider
+}
+
+// Ollama has no forced tool calls, but constrains the reply to a JSON
+// schema, which serves the same purpose.
+func (p *ollama) Complete(ctx context.Context, c Completion) (Reply, error) {
+	reqBody := map[string]interface{}{
+		"model":    c.Model,
+		"messages": chatMessages(c),
+		"stream":   false,
+		"format":   "json",
+		"options":  map[string]interface{}{"num_predict": c.MaxTokens},
+	}
+	if c.Tool != nil {
+		reqBody["format"] = c.Tool.Schema
+	}
+
+	var response struct {
+		Error           string      `json:"error"`
+		Message         chatMessage `json:"message"`
+		PromptEvalCount int         `json:"prompt_eval_count"`
+		EvalCount       int         `json:"eval_count"`
+	}
+	err := p.post(ctx, "/api/chat", nil, reqBody, &response, func(body []byte) string {
+		var r struct {
+			Error string `json:"error"`
+		}
+		json.Unmarshal(body, &r)
+		return r.Error
+	})
+	if err != nil {
+		return Reply{}, err
+	}
+	if response.Error != "" {
+		return Reply{}, fmt.Errorf("API error: %s", response.Error)
+	}
+	usage := Usage{InputTokens: response.PromptEvalCount, OutputTokens: response.EvalCount}
+	if c.Tool != nil {
+		return Reply{ToolInput: json.RawMessage(response.Message.Content), Usage: usage}, nil
+	}
+	return Reply{Text: response.Message.Content, Usage: usage}, nil
+}
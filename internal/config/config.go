@@ -19,6 +19,95 @@ type Config struct {
 	Model    string `json:"model"`
 	CacheTTL int    `json:"cache_ttl"`
 	Logging  bool   `json:"logging"`
+
+	// How long asks stay cached (default: 3600, -1: never cache asks), and
+	// per risk category limits on any cached decision (-1: never cache)
+	CacheAskTTL      int            `json:"cache_ask_ttl,omitempty"`
+	CacheCategoryTTL map[string]int `json:"cache_category_ttl,omitempty"`
+	// Never cache denials, so each one is re-evaluated (default: cached like asks)
+	CacheSkipDenials bool `json:"cache_skip_denials,omitempty"`
+	// Cache size limits; least recently used entries are evicted (default: 10000, 4 MiB)
+	CacheMaxEntries int `json:"cache_max_entries,omitempty"`
+	CacheMaxBytes   int `json:"cache_max_bytes,omitempty"`
+	// "project" keeps cached decisions to the project they were made in (default: global)
+	CacheScope string `json:"cache_scope,omitempty"`
+
+	// What to do when evaluation fails: ask, deny or fallback:<evaluator>[,...]
+	// (default: leave the call to Claude Code)
+	OnError       string `json:"on_error,omitempty"`
+	FallbackModel string `json:"fallback_model,omitempty"`
+	APITimeout    int    `json:"api_timeout,omitempty"` // seconds per evaluation, retries included (default: 30)
+
+	// Prompt budget for the user's intent from the transcript (default: 400, -1: off)
+	TranscriptTokens int `json:"transcript_tokens,omitempty"`
+
+	// Second opinion from a stronger model on uncertain or risky calls
+	Escalation *Escalation `json:"escalation,omitempty"`
+
+	// LLM backend: anthropic (default), openai, ollama, or a key of Providers
+	Provider  string                    `json:"provider,omitempty"`
+	Providers map[string]ProviderConfig `json:"providers,omitempty"`
+
+	// Set by LoadFor when a project config is found
+	ProjectRoot      string   `json:"-"`
+	ProjectUntrusted bool     `json:"-"`
+	ProjectIgnored   []string `json:"-"` // keys a project config may not set
+	PresetDirs       []string `json:"-"`
+}
+
+// ProviderConfig configures an LLM backend.
+type ProviderConfig struct {
+	Type      string            `json:"type,omitempty"` // anthropic, openai or ollama (default: the entry's name)
+	BaseURL   string            `json:"base_url,omitempty"`
+	Model     string            `json:"model,omitempty"`       // overrides model
+	APIKeyEnv string            `json:"api_key_env,omitempty"` // environment variable holding the key
+	CABundle  string            `json:"ca_bundle,omitempty"`   // PEM file of extra CAs to trust
+	Headers   map[string]string `json:"headers,omitempty"`
+}
+
+// Escalation sends the fast model's uncertain or risky calls to a stronger
+// model on the same provider.
+type Escalation struct {
+	Model         string   `json:"model"`
+	MinConfidence float64  `json:"min_confidence,omitempty"` // escalate below this confidence (default: 0.7)
+	Categories    []string `json:"categories,omitempty"`     // always escalate these (default: destructive, privilege)
+	// In these categories both models must agree: the higher risk score wins
+	RequireAgreement []string `json:"require_agreement,omitempty"`
+}
+
+// defaultCacheAskTTL is short so a one-off ask doesn't stop re-evaluation
+// for a day.
+const defaultCacheAskTTL = 3600
+
+// AskTTL returns how long asks stay cached: cache_ask_ttl or the default,
+// -1 for never.
+func (c Config) AskTTL() int {
+	if c.CacheAskTTL == 0 {
+		return defaultCacheAskTTL
+	}
+	return c.CacheAskTTL
+}
+
+// ActiveModel returns the model evaluations use: the active provider's,
+// or Model.
+func (c Config) ActiveModel() string {
+	if _, pc := c.ActiveProvider(); pc.Model != "" {
+		return pc.Model
+	}
+	return c.Model
+}
+
+// ActiveProvider returns the name and settings of the configured backend.
+func (c Config) ActiveProvider() (string, ProviderConfig) {
+	name := c.Provider
+	if name == "" {
+		name = "anthropic"
+	}
+	pc := c.Providers[name]
+	if pc.Type == "" {
+		pc.Type = name
+	}
+	return name, pc
 }
 
 func DefaultConfig() Config {
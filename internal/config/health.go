@@ -0,0 +1,82 @@
+package config
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Health records whether safety evaluation has been failing, so that status
+// can show when ccyolo is running on its on_error policy.
+type Health struct {
+	DegradedSince int64  `json:"degraded_since,omitempty"`
+	Failures      int    `json:"failures,omitempty"`
+	LastError     string `json:"last_error,omitempty"`
+	LastErrorAt   int64  `json:"last_error_at,omitempty"`
+
+	// Calls decided by the on_error policy while degraded
+	Fallbacks      int    `json:"fallbacks,omitempty"`
+	LastFallback   string `json:"last_fallback,omitempty"` // e.g. "fallback:heuristic allow"
+	LastFallbackAt int64  `json:"last_fallback_at,omitempty"`
+}
+
+// Degraded reports whether the last evaluation failed.
+func (h Health) Degraded() bool {
+	return h.DegradedSince != 0
+}
+
+func HealthPath() string {
+	return filepath.Join(ConfigDir(), "health.json")
+}
+
+func LoadHealth() Health {
+	var h Health
+	data, err := os.ReadFile(HealthPath())
+	if err != nil {
+		return h
+	}
+	json.Unmarshal(data, &h)
+	return h
+}
+
+func saveHealth(h Health) error {
+	if err := os.MkdirAll(ConfigDir(), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(h, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(HealthPath(), data, 0644)
+}
+
+// RecordFailure marks evaluation as degraded.
+func RecordFailure(err error) {
+	h := LoadHealth()
+	now := time.Now().Unix()
+	if h.DegradedSince == 0 {
+		h.DegradedSince = now
+	}
+	h.Failures++
+	h.LastError = err.Error()
+	h.LastErrorAt = now
+	saveHealth(h)
+}
+
+// RecordFallback counts a call the on_error policy decided.
+func RecordFallback(source, action string) {
+	h := LoadHealth()
+	h.Fallbacks++
+	h.LastFallback = source + " " + action
+	h.LastFallbackAt = time.Now().Unix()
+	saveHealth(h)
+}
+
+// RecordSuccess clears the degraded state. It only writes when there is
+// something to clear.
+func RecordSuccess() {
+	if h := LoadHealth(); h.Degraded() {
+		os.Remove(HealthPath())
+	}
+}
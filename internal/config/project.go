@@ -0,0 +1,207 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// Project-level policy lives in the repository: a .ccyolo.json layered over
+// the global config, and preset files in .ccyolo/presets/. It is ignored until
+// the user trusts it, and distrusted again whenever its content changes.
+const (
+	ProjectFile = ".ccyolo.json"
+	ProjectDir  = ".ccyolo"
+)
+
+func TrustPath() string {
+	return filepath.Join(ConfigDir(), "trust.json")
+}
+
+// FindProject walks up from dir to the nearest directory holding project
+// policy. The home directory is skipped since ~/.ccyolo is the global config.
+func FindProject(dir string) string {
+	if dir == "" {
+		return ""
+	}
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return ""
+	}
+	home, _ := os.UserHomeDir()
+
+	for {
+		if dir != home {
+			if fileExists(filepath.Join(dir, ProjectFile)) || dirExists(ProjectPresetsDir(dir)) {
+				return dir
+			}
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+func ProjectPresetsDir(root string) string {
+	return filepath.Join(root, ProjectDir, "presets")
+}
+
+// ProjectFiles lists the policy files under a project root, sorted.
+func ProjectFiles(root string) []string {
+	var files []string
+	if path := filepath.Join(root, ProjectFile); fileExists(path) {
+		files = append(files, path)
+	}
+	matches, _ := filepath.Glob(filepath.Join(ProjectPresetsDir(root), "*.json"))
+	sort.Strings(matches)
+	return append(files, matches...)
+}
+
+// ProjectHash fingerprints every policy file under a project root.
+func ProjectHash(root string) string {
+	h := sha256.New()
+	for _, path := range ProjectFiles(root) {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		rel, _ := filepath.Rel(root, path)
+		h.Write([]byte(rel))
+		h.Write([]byte{0})
+		h.Write(data)
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func loadTrust() map[string]string {
+	trusted := make(map[string]string)
+	data, err := os.ReadFile(TrustPath())
+	if err != nil {
+		return trusted
+	}
+	json.Unmarshal(data, &trusted)
+	return trusted
+}
+
+func saveTrust(trusted map[string]string) error {
+	if err := os.MkdirAll(ConfigDir(), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(trusted, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(TrustPath(), data, 0600)
+}
+
+// IsTrusted reports whether the project's policy files are unchanged since
+// the user last ran `ccyolo trust`.
+func IsTrusted(root string) bool {
+	hash, ok := loadTrust()[root]
+	return ok && hash == ProjectHash(root)
+}
+
+func Trust(root string) error {
+	trusted := loadTrust()
+	trusted[root] = ProjectHash(root)
+	return saveTrust(trusted)
+}
+
+func Untrust(root string) error {
+	trusted := loadTrust()
+	delete(trusted, root)
+	return saveTrust(trusted)
+}
+
+// projectConfig holds the keys a project config may set. Providers,
+// endpoints, headers, models and error handling stay global, so a
+// repository can't send the user's API key to a host of its choosing or
+// loosen what happens on failures.
+type projectConfig struct {
+	Preset           string         `json:"preset"`
+	CacheTTL         *int           `json:"cache_ttl"`
+	CacheAskTTL      *int           `json:"cache_ask_ttl"`
+	CacheCategoryTTL map[string]int `json:"cache_category_ttl"`
+}
+
+// projectKeys are the keys of projectConfig.
+var projectKeys = map[string]bool{
+	"preset": true, "cache_ttl": true, "cache_ask_ttl": true, "cache_category_ttl": true,
+}
+
+// apply layers a project config over cfg and returns the keys it ignored.
+func (pc projectConfig) apply(cfg *Config, data []byte) []string {
+	var ignored []string
+	var keys map[string]json.RawMessage
+	json.Unmarshal(data, &keys)
+	for key := range keys {
+		if !projectKeys[key] {
+			ignored = append(ignored, key)
+		}
+	}
+	sort.Strings(ignored)
+
+	if pc.Preset != "" {
+		cfg.Preset = pc.Preset
+	}
+	if pc.CacheTTL != nil {
+		cfg.CacheTTL = *pc.CacheTTL
+	}
+	if pc.CacheAskTTL != nil {
+		cfg.CacheAskTTL = *pc.CacheAskTTL
+	}
+	if len(pc.CacheCategoryTTL) > 0 {
+		merged := make(map[string]int, len(cfg.CacheCategoryTTL)+len(pc.CacheCategoryTTL))
+		for k, v := range cfg.CacheCategoryTTL {
+			merged[k] = v
+		}
+		for k, v := range pc.CacheCategoryTTL {
+			merged[k] = v
+		}
+		cfg.CacheCategoryTTL = merged
+	}
+	return ignored
+}
+
+// LoadFor loads the global config and layers a trusted project config found
+// by walking up from cwd on top of it. Only the keys of projectConfig are
+// applied; the others are listed in ProjectIgnored.
+func LoadFor(cwd string) Config {
+	cfg := Load()
+
+	root := FindProject(cwd)
+	if root == "" {
+		return cfg
+	}
+	cfg.ProjectRoot = root
+
+	if !IsTrusted(root) {
+		cfg.ProjectUntrusted = true
+		return cfg
+	}
+
+	if data, err := os.ReadFile(filepath.Join(root, ProjectFile)); err == nil {
+		var pc projectConfig
+		if json.Unmarshal(data, &pc) == nil {
+			cfg.ProjectIgnored = pc.apply(&cfg, data)
+		}
+	}
+	cfg.PresetDirs = []string{ProjectPresetsDir(root)}
+	return cfg
+}
+
+func fileExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
+}
+
+func dirExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
@@ -0,0 +1,282 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/9roads/ccyolo/internal/claude"
+	"github.com/9roads/ccyolo/internal/config"
+	"github.com/9roads/ccyolo/internal/hook"
+	"github.com/9roads/ccyolo/internal/preset"
+	"github.com/9roads/ccyolo/internal/usage"
+)
+
+// Pass stops evaluation without a decision of ccyolo's own: Claude Code's
+// normal permission flow takes over. It is what the hook's "{}" means.
+const Pass preset.Action = "pass"
+
+// Request is one tool call to decide, with the settings it runs under.
+type Request struct {
+	Input  hook.Input
+	Preset preset.Preset
+	Config config.Config
+}
+
+// Decision is an evaluator's verdict. An empty Action abstains and lets the
+// next evaluator decide.
+type Decision struct {
+	Action preset.Action // allow, ask, deny, pass or "" (abstain)
+	Reason string        // shown in the hook reason
+	Source string        // evaluator that decided
+
+	// Set by the model evaluators
+	RiskScore    int
+	RiskCategory string
+	Calls        []ModelCall
+}
+
+// ModelCall is one model evaluation behind a decision.
+type ModelCall struct {
+	Tier    string // fast, strong or fallback
+	Model   string
+	Latency time.Duration
+	Usage   claude.Usage
+
+	RiskScore    int
+	RiskCategory string
+	Confidence   float64
+	Failed       bool // the reply was unusable; only the usage counts
+}
+
+func newModelCall(tier string, d *claude.SafetyDecision, err error) ModelCall {
+	return ModelCall{
+		Tier:         tier,
+		Model:        d.Model,
+		Latency:      d.Latency,
+		Usage:        d.Usage,
+		RiskScore:    d.RiskScore,
+		RiskCategory: d.RiskCategory,
+		Confidence:   d.Confidence,
+		Failed:       err != nil,
+	}
+}
+
+func (c ModelCall) String() string {
+	cost := "cost unknown"
+	if usd, ok := c.Usage.Cost(c.Model); ok {
+		cost = fmt.Sprintf("$%.5f", usd)
+	}
+	u := c.Usage
+	verdict := fmt.Sprintf("risk %d, %s, confidence %.2f", c.RiskScore, c.RiskCategory, c.Confidence)
+	if c.Failed {
+		verdict = "unusable reply"
+	}
+	return fmt.Sprintf("%s %s: %s (%dms, %d in, %d out, %d cache write, %d cache read, %s)",
+		c.Tier, c.Model, verdict, c.Latency.Milliseconds(),
+		u.InputTokens, u.OutputTokens, u.CacheWriteTokens, u.CacheReadTokens, cost)
+}
+
+// Risk describes the decision's risk assessment for logs, if it has one.
+func (d Decision) Risk() string {
+	if d.RiskCategory == "" {
+		return ""
+	}
+	return fmt.Sprintf(" [risk %d, %s]", d.RiskScore, d.RiskCategory)
+}
+
+// Abstained reports whether the evaluator left the call to the next one.
+func (d Decision) Abstained() bool {
+	return d.Action == ""
+}
+
+// Evaluator is one stage of the decision pipeline. With an error, only the
+// decision's Calls are used: the model calls made before it failed.
+type Evaluator interface {
+	Name() string
+	Evaluate(ctx context.Context, req Request) (Decision, error)
+}
+
+// cacher is implemented by evaluators whose decisions are worth caching.
+type cacher interface {
+	cacheable(cfg config.Config, d Decision) bool
+}
+
+// remote is implemented by evaluators that call out to a model; their
+// failures and recoveries are recorded in the health state.
+type remote interface {
+	remote()
+}
+
+// DefaultPipeline is used by presets that don't set Pipeline.
+var DefaultPipeline = []string{"secret-scan", "static-rules", "mode", "cache", "llm"}
+
+// Engine runs a preset's evaluators in order; the first one that doesn't
+// abstain decides.
+type Engine struct {
+	Evaluators []Evaluator
+
+	// Log receives progress messages; nil discards them
+	Log func(format string, args ...interface{})
+}
+
+// New builds the engine for a preset's pipeline. The secret scan always
+// runs first, wherever the pipeline lists it, so no rule or cached allow
+// can skip it; the preset's Secrets setting turns it off.
+func New(p preset.Preset) (*Engine, error) {
+	names := p.Pipeline
+	if len(names) == 0 {
+		names = DefaultPipeline
+	}
+	e := &Engine{Evaluators: []Evaluator{secretScan{}}}
+	for _, name := range names {
+		if name == "secret-scan" {
+			continue
+		}
+		ev, ok := lookup(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown evaluator %q in pipeline", name)
+		}
+		e.Evaluators = append(e.Evaluators, ev)
+	}
+	return e, nil
+}
+
+// Without returns a copy of the engine that skips the named evaluators.
+func (e *Engine) Without(names ...string) *Engine {
+	skip := make(map[string]bool)
+	for _, name := range names {
+		skip[name] = true
+	}
+	out := &Engine{Log: e.Log}
+	for _, ev := range e.Evaluators {
+		if !skip[ev.Name()] {
+			out.Evaluators = append(out.Evaluators, ev)
+		}
+	}
+	return out
+}
+
+// Evaluate runs the pipeline. If every evaluator abstains the result is
+// Pass. An evaluator error stops the pipeline; the decision then comes from
+// the on_error policy and the error is returned as well.
+func (e *Engine) Evaluate(ctx context.Context, req Request) (Decision, error) {
+	for _, ev := range e.Evaluators {
+		d, err := ev.Evaluate(ctx, req)
+		if _, ok := ev.(remote); ok {
+			if err != nil {
+				config.RecordFailure(err)
+			} else {
+				config.RecordSuccess()
+			}
+		}
+		if err != nil {
+			e.record(req, ev.Name(), d.Calls)
+			e.log("%s: error: %v", ev.Name(), err)
+			return e.checkMode(req, e.onError(ctx, req, ev.Name(), err)), err
+		}
+		if d.Abstained() {
+			e.log("%s: abstain", ev.Name())
+			continue
+		}
+		d.Source = ev.Name()
+		e.record(req, ev.Name(), d.Calls)
+		e.log("%s: %s (%s)%s", ev.Name(), d.Action, d.Reason, d.Risk())
+
+		if c, ok := ev.(cacher); ok && c.cacheable(req.Config, d) {
+			e.store(req, d)
+		}
+		return e.checkMode(req, d), nil
+	}
+	return Decision{Action: Pass, Reason: "no evaluator decided"}, nil
+}
+
+// onError applies the configured on_error policy after an evaluator failed:
+// "ask", "deny", or "fallback:" and a comma-separated list of evaluators
+// tried in order. Anything else, or fallbacks that all abstain, pass.
+func (e *Engine) onError(ctx context.Context, req Request, source string, err error) Decision {
+	policy := req.Config.OnError
+	reason := source + " failed: " + err.Error()
+
+	switch {
+	case policy == string(preset.Ask) || policy == string(preset.Deny):
+		config.RecordFallback("on_error", policy)
+		return Decision{Action: preset.Action(policy), Reason: reason, Source: "on_error"}
+
+	case strings.HasPrefix(policy, "fallback:"):
+		for _, name := range strings.Split(strings.TrimPrefix(policy, "fallback:"), ",") {
+			name = strings.TrimSpace(name)
+			ev, ok := lookup(name)
+			if !ok {
+				e.log("on_error: unknown evaluator %q", name)
+				continue
+			}
+			d, err := ev.Evaluate(ctx, req)
+			if err != nil {
+				e.record(req, "on_error: "+name, d.Calls)
+				e.log("on_error: %s: error: %v", name, err)
+				continue
+			}
+			if !d.Abstained() {
+				d.Source = "fallback:" + name
+				config.RecordFallback(d.Source, string(d.Action))
+				e.record(req, "on_error: "+name, d.Calls)
+				e.log("on_error: %s: %s (%s)%s", name, d.Action, d.Reason, d.Risk())
+				return d
+			}
+		}
+	}
+	return Decision{Action: Pass, Reason: reason, Source: source}
+}
+
+// ValidateOnError checks an on_error policy.
+func ValidateOnError(policy string) error {
+	switch {
+	case policy == "" || policy == string(preset.Ask) || policy == string(preset.Deny):
+		return nil
+	case strings.HasPrefix(policy, "fallback:"):
+		for _, name := range strings.Split(strings.TrimPrefix(policy, "fallback:"), ",") {
+			if _, ok := lookup(strings.TrimSpace(name)); !ok {
+				return fmt.Errorf("on_error: unknown evaluator %q", strings.TrimSpace(name))
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("on_error: want ask, deny or fallback:<evaluator>, got %q", policy)
+}
+
+// checkMode keeps allows within the permission mode: in plan mode only
+// read-only tools are approved.
+func (e *Engine) checkMode(req Request, d Decision) Decision {
+	in := req.Input
+	if d.Action == preset.Allow && in.PermissionMode == hook.ModePlan && !in.ReadOnly() {
+		e.log("plan mode, not approving %s", in.ToolName)
+		d.Action = Pass
+	}
+	return d
+}
+
+// store hands a decision to the pipeline's cache stage, if it has one.
+func (e *Engine) store(req Request, d Decision) {
+	for _, ev := range e.Evaluators {
+		if c, ok := ev.(*cacheEvaluator); ok {
+			c.store(req, d)
+			return
+		}
+	}
+}
+
+// record logs model calls and adds them to the usage counters.
+func (e *Engine) record(req Request, source string, calls []ModelCall) {
+	for _, c := range calls {
+		e.log("%s: %s", source, c)
+		usage.Add(req.Preset.Name, c.Model, c.Tier, c.Usage)
+	}
+}
+
+func (e *Engine) log(format string, args ...interface{}) {
+	if e.Log != nil {
+		e.Log(format, args...)
+	}
+}
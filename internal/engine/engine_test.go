@@ -0,0 +1,47 @@
+package engine
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/9roads/ccyolo/internal/preset"
+)
+
+func TestNewSecretScanFirst(t *testing.T) {
+	for _, pipeline := range [][]string{
+		nil,
+		{"static-rules", "secret-scan", "cache", "llm"},
+		{"static-rules", "llm"},
+		{"secret-scan", "static-rules"},
+	} {
+		e, err := New(preset.Preset{Pipeline: pipeline})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var names []string
+		for _, ev := range e.Evaluators {
+			names = append(names, ev.Name())
+		}
+		if names[0] != "secret-scan" {
+			t.Errorf("pipeline %v: runs %v, want secret-scan first", pipeline, names)
+		}
+		for _, name := range names[1:] {
+			if name == "secret-scan" {
+				t.Errorf("pipeline %v: runs %v, want secret-scan once", pipeline, names)
+			}
+		}
+	}
+
+	e, _ := New(preset.Preset{Pipeline: []string{"static-rules", "secret-scan", "llm"}})
+	var names []string
+	for _, ev := range e.Evaluators {
+		names = append(names, ev.Name())
+	}
+	if want := []string{"secret-scan", "static-rules", "llm"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("got %v, want %v", names, want)
+	}
+
+	if _, err := New(preset.Preset{Pipeline: []string{"static-rules", "oracle"}}); err == nil {
+		t.Error("unknown evaluator: want error")
+	}
+}
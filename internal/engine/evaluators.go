@@ -0,0 +1,323 @@
+package engine
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/9roads/ccyolo/internal/cache"
+	"github.com/9roads/ccyolo/internal/claude"
+	"github.com/9roads/ccyolo/internal/config"
+	"github.com/9roads/ccyolo/internal/hook"
+	"github.com/9roads/ccyolo/internal/paths"
+	"github.com/9roads/ccyolo/internal/preset"
+	"github.com/9roads/ccyolo/internal/transcript"
+)
+
+func lookup(name string) (Evaluator, bool) {
+	switch name {
+	case "secret-scan":
+		return secretScan{}, true
+	case "static-rules":
+		return staticRules{}, true
+	case "mode":
+		return modeEvaluator{}, true
+	case "cache":
+		return &cacheEvaluator{}, true
+	case "llm":
+		return llm{}, true
+	case "heuristic":
+		return heuristic{}, true
+	case "stale-cache":
+		return staleCache{}, true
+	case "fallback-model":
+		return llm{fallback: true}, true
+	}
+	return nil, false
+}
+
+func fromRule(m *preset.RuleMatch) Decision {
+	if m == nil {
+		return Decision{}
+	}
+	return Decision{Action: m.Action, Reason: m.Reason()}
+}
+
+// secretScan looks for credentials in content being written.
+type secretScan struct{}
+
+func (secretScan) Name() string { return "secret-scan" }
+
+func (secretScan) Evaluate(ctx context.Context, req Request) (Decision, error) {
+	return fromRule(preset.CheckSecrets(req.Input, req.Preset)), nil
+}
+
+// staticRules applies the preset's rules and its MCP and web policies.
+type staticRules struct{}
+
+func (staticRules) Name() string { return "static-rules" }
+
+func (staticRules) Evaluate(ctx context.Context, req Request) (Decision, error) {
+	return fromRule(preset.CheckRules(req.Input, req.Preset)), nil
+}
+
+// modeEvaluator leaves calls to Claude Code where the permission mode
+// already decides them: edits in acceptEdits mode, and anything but
+// read-only work in plan mode.
+type modeEvaluator struct{}
+
+func (modeEvaluator) Name() string { return "mode" }
+
+func (modeEvaluator) Evaluate(ctx context.Context, req Request) (Decision, error) {
+	in := req.Input
+	if in.PermissionMode == hook.ModeAcceptEdits && in.Edit() ||
+		in.PermissionMode == hook.ModePlan && !in.ReadOnly() {
+		return Decision{Action: Pass, Reason: in.PermissionMode + " mode"}, nil
+	}
+	return Decision{}, nil
+}
+
+// cacheEvaluator serves earlier decisions of the evaluators after it.
+type cacheEvaluator struct{}
+
+func (*cacheEvaluator) Name() string { return "cache" }
+
+func (*cacheEvaluator) Evaluate(ctx context.Context, req Request) (Decision, error) {
+	return fromCache(cache.Get(req.Input, cacheScope(req), req.Config.CacheTTL), "cached"), nil
+}
+
+func (*cacheEvaluator) store(req Request, d Decision) {
+	ttl := cacheTTL(req, d)
+	if ttl <= 0 {
+		return
+	}
+	cache.Set(req.Input, cacheScope(req), cache.Entry{
+		Approve:      d.Action == preset.Allow,
+		Deny:         d.Action == preset.Deny,
+		TTL:          ttl,
+		Reason:       d.Reason,
+		Source:       d.Source,
+		RiskScore:    d.RiskScore,
+		RiskCategory: d.RiskCategory,
+	})
+}
+
+// cacheTTL returns how long a decision stays cached, -1 for not caching
+// it: cache_ttl for allows and cache_ask_ttl for asks and denials,
+// shortened by the risk category's cache_category_ttl and by preset TTL
+// rules matching the call. The shortest wins.
+func cacheTTL(req Request, d Decision) int {
+	cfg := req.Config
+	ttl := cfg.CacheTTL
+	if d.Action != preset.Allow {
+		ttl = cfg.AskTTL()
+	}
+	if t, ok := cfg.CacheCategoryTTL[d.RiskCategory]; ok && d.RiskCategory != "" {
+		ttl = preset.ShorterTTL(ttl, t)
+	}
+	if t, ok := preset.CacheTTL(req.Input, req.Preset); ok {
+		ttl = preset.ShorterTTL(ttl, t)
+	}
+	return ttl
+}
+
+// cacheScope ties cached decisions to the preset's current policy and the
+// models that made them, and with cache_scope "project" to the project.
+func cacheScope(req Request) cache.Scope {
+	cfg := req.Config
+	name, _ := cfg.ActiveProvider()
+	models := name + ":" + cfg.ActiveModel()
+	if esc := cfg.Escalation; esc != nil && esc.Model != "" {
+		data, _ := json.Marshal(esc)
+		models += ":" + string(data)
+	}
+	hash := sha256.Sum256([]byte(req.Preset.Fingerprint() + ":" + models))
+
+	scope := cache.Scope{
+		Preset:      req.Preset.Name,
+		Fingerprint: hex.EncodeToString(hash[:8]),
+		Normalize:   req.Preset.NormalizeCommand,
+	}
+	if cfg.CacheScope == "project" && req.Input.Cwd != "" {
+		scope.Project = paths.ProjectRoot(req.Input.Cwd)
+	}
+	return scope
+}
+
+func fromCache(e *cache.Entry, reason string) Decision {
+	if e == nil {
+		return Decision{}
+	}
+	if e.Reason != "" {
+		reason += ", " + e.Reason
+	}
+	d := Decision{Action: Pass, Reason: reason, RiskScore: e.RiskScore, RiskCategory: e.RiskCategory}
+	switch {
+	case e.Approve:
+		d.Action = preset.Allow
+	case e.Deny:
+		d.Action = preset.Deny
+	}
+	return d
+}
+
+// staleCache serves cached decisions past their TTL. It is meant as an
+// on_error fallback.
+type staleCache struct{}
+
+func (staleCache) Name() string { return "stale-cache" }
+
+func (staleCache) Evaluate(ctx context.Context, req Request) (Decision, error) {
+	return fromCache(cache.GetStale(req.Input, cacheScope(req), req.Config.CacheTTL), "cached (stale)"), nil
+}
+
+// defaultAPITimeout bounds a model evaluation, retries included.
+const defaultAPITimeout = 30 * time.Second
+
+// llm asks the model to judge the call with the preset's prompt. The
+// fallback variant uses the configured fallback_model and abstains without
+// one.
+type llm struct {
+	fallback bool
+}
+
+func (l llm) Name() string {
+	if l.fallback {
+		return "fallback-model"
+	}
+	return "llm"
+}
+
+func (l llm) Evaluate(ctx context.Context, req Request) (Decision, error) {
+	if l.fallback && req.Config.FallbackModel == "" {
+		return Decision{}, nil
+	}
+
+	provider, model, err := claude.NewProvider(req.Config)
+	if err != nil {
+		return Decision{}, err
+	}
+	if l.fallback {
+		model = req.Config.FallbackModel
+	}
+
+	timeout := time.Duration(req.Config.APITimeout) * time.Second
+	if timeout <= 0 {
+		timeout = defaultAPITimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	sreq := claude.SafetyRequest{
+		Model:  model,
+		Prompt: req.Preset.Prompt,
+		Input:  req.Input,
+		Intent: intent(req),
+	}
+	tier := "fast"
+	if l.fallback {
+		tier = "fallback"
+	}
+	// Calls made before an error are returned with it, to be recorded
+	var calls []ModelCall
+	result, err := claude.EvaluateSafety(ctx, provider, sreq)
+	if result != nil {
+		calls = append(calls, newModelCall(tier, result, err))
+	}
+	if err != nil {
+		return Decision{Calls: calls}, err
+	}
+
+	if esc := req.Config.Escalation; !l.fallback && esc != nil && esc.Model != "" && escalate(esc, result) {
+		sreq.Model = esc.Model
+		strong, err := claude.EvaluateSafety(ctx, provider, sreq)
+		if strong != nil {
+			calls = append(calls, newModelCall("strong", strong, err))
+		}
+		if err != nil {
+			return Decision{Calls: calls}, fmt.Errorf("escalation to %s: %w", esc.Model, err)
+		}
+		if !inCategories(esc.RequireAgreement, result.RiskCategory, strong.RiskCategory) || strong.RiskScore >= result.RiskScore {
+			result = strong
+		}
+	}
+
+	d := Decision{
+		Action:       req.Preset.RiskThresholds().Action(result.RiskScore),
+		Reason:       "AI: " + result.Reason,
+		RiskScore:    result.RiskScore,
+		RiskCategory: result.RiskCategory,
+		Calls:        calls,
+	}
+	if d.Action == preset.Ask {
+		// Asking is Claude Code's own default
+		d.Action = Pass
+	}
+	return d, nil
+}
+
+// Defaults for escalation settings left unset
+const defaultMinConfidence = 0.7
+
+var defaultEscalateCategories = []string{claude.RiskDestructive, claude.RiskPrivilege}
+
+// escalate reports whether the fast model's verdict needs a second opinion.
+func escalate(esc *config.Escalation, d *claude.SafetyDecision) bool {
+	minConfidence := esc.MinConfidence
+	if minConfidence == 0 {
+		minConfidence = defaultMinConfidence
+	}
+	categories := esc.Categories
+	if categories == nil {
+		categories = defaultEscalateCategories
+	}
+	return d.Confidence < minConfidence || inCategories(categories, d.RiskCategory) ||
+		inCategories(esc.RequireAgreement, d.RiskCategory)
+}
+
+func inCategories(categories []string, risks ...string) bool {
+	for _, c := range categories {
+		for _, r := range risks {
+			if c == r {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// cacheable keeps allows, asks (passed to Claude Code) and, unless
+// cache_skip_denials is set, denials.
+func (l llm) cacheable(cfg config.Config, d Decision) bool {
+	switch d.Action {
+	case preset.Allow, Pass:
+		return true
+	case preset.Deny:
+		return !cfg.CacheSkipDenials
+	}
+	return false
+}
+
+func (l llm) remote() {}
+
+// defaultTranscriptTokens is the prompt budget for the user's intent.
+const defaultTranscriptTokens = 400
+
+// intent reads what the user asked for from the session transcript.
+func intent(req Request) string {
+	budget := req.Config.TranscriptTokens
+	if budget < 0 || req.Input.TranscriptPath == "" {
+		return ""
+	}
+	if budget == 0 {
+		budget = defaultTranscriptTokens
+	}
+	in, err := transcript.Read(req.Input.TranscriptPath)
+	if err != nil {
+		return ""
+	}
+	return in.Describe(budget)
+}
@@ -0,0 +1,78 @@
+package engine
+
+import (
+	"context"
+	"testing"
+
+	"github.com/9roads/ccyolo/internal/config"
+	"github.com/9roads/ccyolo/internal/hook"
+	"github.com/9roads/ccyolo/internal/preset"
+)
+
+func TestCacheTTL(t *testing.T) {
+	cfg := config.Config{
+		CacheTTL:         3600,
+		CacheAskTTL:      600,
+		CacheCategoryTTL: map[string]int{"destructive": 900, "privilege": -1},
+	}
+	p := preset.Preset{Rules: []preset.Rule{
+		{Tool: "Bash", Pattern: "git push*", CacheTTL: 300},
+		{Tool: "Bash", Pattern: "make*", CacheTTL: 86400},
+	}}
+	tests := []struct {
+		command  string
+		action   preset.Action
+		category string
+		want     int
+	}{
+		{"ls", preset.Allow, "", 3600},
+		{"ls", preset.Ask, "", 600},
+		{"rm x", preset.Allow, "destructive", 900},
+		{"git push", preset.Allow, "", 300},
+		{"git push", preset.Allow, "privilege", -1}, // the rule can't lift a category's -1
+		{"make", preset.Allow, "", 3600},            // nor lengthen cache_ttl
+		{"make", preset.Ask, "", 600},
+	}
+	for _, tt := range tests {
+		req := Request{
+			Input:  hook.Input{ToolName: "Bash", ToolInput: map[string]interface{}{"command": tt.command}},
+			Preset: p,
+			Config: cfg,
+		}
+		d := Decision{Action: tt.action, RiskCategory: tt.category}
+		if got := cacheTTL(req, d); got != tt.want {
+			t.Errorf("cacheTTL(%q, %s, %q) = %d, want %d", tt.command, tt.action, tt.category, got, tt.want)
+		}
+	}
+}
+
+func TestCacheDenials(t *testing.T) {
+	in := hook.Input{ToolName: "Bash", ToolInput: map[string]interface{}{"command": "rm -rf /"}}
+	deny := Decision{Action: preset.Deny, Reason: "AI: no", RiskScore: 95, RiskCategory: "destructive"}
+
+	for _, skip := range []bool{false, true} {
+		t.Setenv("HOME", t.TempDir())
+		cfg := config.Config{CacheTTL: 3600, CacheSkipDenials: skip}
+		if got := (llm{}).cacheable(cfg, deny); got == skip {
+			t.Errorf("cache_skip_denials %v: cacheable = %v", skip, got)
+		}
+
+		// A cached denial is served as one
+		e := &Engine{Evaluators: []Evaluator{&cacheEvaluator{}}}
+		req := Request{Input: in, Config: cfg}
+		if (llm{}).cacheable(cfg, deny) {
+			e.store(req, deny)
+		}
+		d, err := e.Evaluate(context.Background(), req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := preset.Deny
+		if skip {
+			want = Pass // nothing cached
+		}
+		if d.Action != want {
+			t.Errorf("cache_skip_denials %v: got %s, want %s", skip, d.Action, want)
+		}
+	}
+}
@@ -0,0 +1,224 @@
+package engine
+
+import (
+	"context"
+	"path"
+	"strings"
+
+	"github.com/9roads/ccyolo/internal/paths"
+	"github.com/9roads/ccyolo/internal/preset"
+	"github.com/9roads/ccyolo/internal/shell"
+)
+
+// heuristic is a local classifier for when the model can't be asked. It
+// allows calls that are clearly harmless, asks for clearly risky ones and
+// abstains on the rest.
+type heuristic struct{}
+
+func (heuristic) Name() string { return "heuristic" }
+
+// safeCommands only read, or build and test the project. A program maps to
+// its safe subcommands, or nil if any use is safe short of writeFlags.
+var safeCommands = map[string][]string{
+	"ls": nil, "cat": nil, "head": nil, "tail": nil, "less": nil, "wc": nil,
+	"grep": nil, "rg": nil, "ag": nil, "pwd": nil, "echo": nil, "printf": nil,
+	"which": nil, "whoami": nil, "date": nil, "file": nil, "stat": nil, "du": nil,
+	"df": nil, "tree": nil, "diff": nil, "sort": nil, "uniq": nil, "cut": nil,
+	"tr": nil, "jq": nil, "basename": nil, "dirname": nil, "realpath": nil,
+	"true": nil, "test": nil,
+	"git":    {"status", "log", "diff", "show", "branch", "rev-parse", "ls-files", "blame", "describe"},
+	"go":     {"build", "test", "vet", "list", "version", "env", "doc"},
+	"cargo":  {"build", "test", "check", "clippy"},
+	"npm":    {"test", "ls", "view"},
+	"yarn":   {"test"},
+	"pnpm":   {"test"},
+	"pytest": nil,
+}
+
+// writeFlags make an otherwise safe command write files, change system
+// settings or run other programs. Keys are a program, or a program and its
+// subcommand. A single-letter flag also counts inside a cluster ("-fo"),
+// except for programs in wordFlags.
+var writeFlags = map[string][]string{
+	"go":           {"-o", "-exec", "-toolexec", "-vettool"},
+	"cargo":        {"--config"},
+	"cargo clippy": {"--fix"},
+	"sort":         {"-o", "--output", "--compress-program"},
+	"tree":         {"-o"},
+	"date":         {"-s", "--set"},
+	"file":         {"-C", "--compile"},
+	"rg":           {"--pre"},
+	"git":          {"--output"},
+	"go env":       {"-w", "-u"},
+	"git branch":   {"-d", "-D", "--delete", "-f", "--force", "-m", "-M", "--move", "-c", "-C", "--copy", "-u", "--set-upstream-to", "--unset-upstream", "--edit-description", "-t", "--track"},
+}
+
+// wordFlags take single-dash flags that are words ("-race"), not clusters
+// of letters. A leading "--" works as well.
+var wordFlags = map[string]bool{"go": true}
+
+// riskyCommands need a human whatever their arguments.
+var riskyCommands = map[string]bool{
+	"sudo": true, "su": true, "doas": true, "dd": true, "mkfs": true, "shred": true,
+	"chmod": true, "chown": true, "curl": true, "wget": true, "ssh": true, "scp": true,
+	"rsync": true, "nc": true, "ncat": true, "socat": true, "kubectl": true,
+	"terraform": true, "docker": true, "systemctl": true, "shutdown": true, "reboot": true,
+}
+
+func (heuristic) Evaluate(ctx context.Context, req Request) (Decision, error) {
+	in := req.Input
+
+	switch {
+	case in.ToolName == "Bash":
+		command, _ := in.ToolInput["command"].(string)
+		return classifyCommand(command), nil
+
+	case in.ToolName == "WebFetch" || in.ToolName == "WebSearch":
+		// Whether a site is trustworthy is beyond a heuristic
+		return Decision{}, nil
+
+	case in.ReadOnly():
+		return Decision{Action: preset.Allow, Reason: "heuristic: read-only tool"}, nil
+
+	case in.Edit():
+		p, _ := in.ToolInput["file_path"].(string)
+		if p == "" {
+			p, _ = in.ToolInput["notebook_path"].(string)
+		}
+		p = paths.Normalize(p, in.Cwd)
+		if p != "" && paths.Within(p, paths.ProjectRoot(in.Cwd)) {
+			return Decision{Action: preset.Allow, Reason: "heuristic: edit inside the project"}, nil
+		}
+		return Decision{Action: preset.Ask, Reason: "heuristic: edit outside the project"}, nil
+	}
+	return Decision{}, nil
+}
+
+func classifyCommand(command string) Decision {
+	cmds, err := shell.Parse(command)
+	if err != nil || len(cmds) == 0 {
+		return Decision{}
+	}
+
+	safe := true
+	for _, c := range cmds {
+		if len(c.Args) == 0 {
+			continue
+		}
+		prog := path.Base(c.Args[0])
+		if riskyCommands[prog] || strings.HasPrefix(prog, "mkfs.") || isRecursiveRm(c.Args) {
+			return Decision{Action: preset.Ask, Reason: "heuristic: " + prog + " is risky"}
+		}
+		if !safeCommand(prog, c) {
+			safe = false
+		}
+	}
+	if !safe {
+		return Decision{}
+	}
+	return Decision{Action: preset.Allow, Reason: "heuristic: read-only or build command"}
+}
+
+func safeCommand(prog string, c shell.Command) bool {
+	if len(c.WrittenFiles()) > 0 {
+		return false
+	}
+	subcommands, ok := safeCommands[prog]
+	if !ok || hasFlag(c.Args[1:], writeFlags[prog], wordFlags[prog]) {
+		return false
+	}
+	operands := operands(c.Args[1:])
+	if subcommands == nil {
+		switch prog {
+		case "uniq":
+			// uniq IN OUT writes OUT
+			return len(operands) < 2
+		case "date":
+			// An operand other than +FORMAT sets the clock
+			for _, op := range operands {
+				if !strings.HasPrefix(op, "+") {
+					return false
+				}
+			}
+		}
+		return true
+	}
+	if len(operands) == 0 || !containsString(subcommands, operands[0]) {
+		return false
+	}
+	args := c.Args[1:]
+	for i, arg := range args {
+		if arg == operands[0] {
+			args = args[i+1:]
+			break
+		}
+	}
+	if hasFlag(args, writeFlags[prog+" "+operands[0]], wordFlags[prog]) {
+		return false
+	}
+	// git branch NAME creates a branch; only --list takes patterns
+	if prog == "git" && operands[0] == "branch" && len(operands) > 1 && !hasFlag(args, []string{"-l", "--list"}, false) {
+		return false
+	}
+	return true
+}
+
+// operands returns the arguments that aren't flags.
+func operands(args []string) []string {
+	var ops []string
+	for _, arg := range args {
+		if !strings.HasPrefix(arg, "-") || arg == "-" {
+			ops = append(ops, arg)
+		}
+	}
+	return ops
+}
+
+// hasFlag reports whether args use one of flags, as "--flag", "--flag=x",
+// "-f", "-fVALUE" or within a cluster like "-xf". With words, flags are
+// single-dash words instead: "-flag", "--flag" and either with "=x".
+func hasFlag(args, flags []string, words bool) bool {
+	for _, arg := range args {
+		if arg == "--" {
+			return false
+		}
+		for _, f := range flags {
+			if words {
+				name, _, _ := strings.Cut(strings.TrimPrefix(arg, "-"), "=")
+				if strings.HasPrefix(arg, "-") && "-"+strings.TrimPrefix(name, "-") == f {
+					return true
+				}
+				continue
+			}
+			switch {
+			case arg == f || strings.HasPrefix(f, "--") && strings.HasPrefix(arg, f+"="):
+				return true
+			case len(f) == 2 && f[0] == '-' && f[1] != '-' &&
+				len(arg) > 1 && arg[0] == '-' && arg[1] != '-' && strings.IndexByte(arg[1:], f[1]) >= 0:
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+func isRecursiveRm(args []string) bool {
+	if path.Base(args[0]) != "rm" {
+		return false
+	}
+	for _, arg := range args[1:] {
+		if arg == "--recursive" || strings.HasPrefix(arg, "-") && !strings.HasPrefix(arg, "--") && strings.ContainsAny(arg, "rR") {
+			return true
+		}
+	}
+	return false
+}
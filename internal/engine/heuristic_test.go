@@ -0,0 +1,67 @@
+package engine
+
+import (
+	"testing"
+
+	"github.com/9roads/ccyolo/internal/preset"
+)
+
+func TestClassifyCommand(t *testing.T) {
+	tests := []struct {
+		command string
+		want    preset.Action // "" to abstain
+	}{
+		{"ls -la && git status", preset.Allow},
+		{"sort -rn f | uniq -c", preset.Allow},
+		{"uniq in", preset.Allow},
+		{"tree -L 2", preset.Allow},
+		{"date +%s", preset.Allow},
+		{"git branch -a", preset.Allow},
+		{"git branch --list 'feat/*'", preset.Allow},
+		{"go env GOPATH", preset.Allow},
+		{"go test -race -cover ./...", preset.Allow},
+		{"go build ./...", preset.Allow},
+		{"cargo clippy -- -D warnings", preset.Allow},
+		{"grep x f > /dev/null", preset.Allow},
+		{"grep x f 2>&1", preset.Allow},
+
+		// Write-capable forms of safe commands
+		{"sort -o f g", ""},
+		{"sort -no f g", ""},
+		{"sort --output=f g", ""},
+		{"uniq in out", ""},
+		{"tree -o f", ""},
+		{"date -s 2020-01-01", ""},
+		{"date 0101000020", ""},
+		{"file -C -m magic", ""},
+		{"rg --pre ./x y", ""},
+		{"git branch -D main", ""},
+		{"git branch -f main HEAD~3", ""},
+		{"git branch new", ""},
+		{"git diff --output=f", ""},
+		{"go env -w GOFLAGS=-x", ""},
+
+		// Flags that run other programs or write outside the project, and
+		// formatters that rewrite files
+		{"go test -exec ./evil ./...", ""},
+		{"go test -exec=./evil ./...", ""},
+		{"go build -toolexec /tmp/x ./...", ""},
+		{"go vet -vettool=/tmp/x ./...", ""},
+		{"go build -o /usr/local/bin/x .", ""},
+		{"go build --o=x .", ""},
+		{"go fmt ./...", ""},
+		{"cargo fmt", ""},
+		{"cargo clippy --fix", ""},
+		{"cargo --config build.rustc-wrapper=./x build", ""},
+		{"cat f > g", ""},
+		{"make", ""},
+
+		{"sudo ls", preset.Ask},
+		{"ls && rm -rf x", preset.Ask},
+	}
+	for _, tt := range tests {
+		if got := classifyCommand(tt.command); got.Action != tt.want {
+			t.Errorf("classifyCommand(%q) = %q, want %q", tt.command, got.Action, tt.want)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/9roads/ccyolo/internal/config"
+	"github.com/9roads/ccyolo/internal/hook"
+	"github.com/9roads/ccyolo/internal/usage"
+)
+
+// TestLLMRecordsFailedCalls checks that calls made before an error still
+// reach the usage counters.
+func TestLLMRecordsFailedCalls(t *testing.T) {
+	// "good" answers with a destructive call, which escalates; any other
+	// model's risk score is out of range
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct{ Model string }
+		json.NewDecoder(r.Body).Decode(&body)
+		score := 150
+		if body.Model == "good" {
+			score = 10
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"usage": map[string]int{"input_tokens": 100, "output_tokens": 20},
+			"content": []map[string]interface{}{{
+				"type": "tool_use", "name": "safety_decision",
+				"input": map[string]interface{}{"risk_score": score, "reason": "x", "risk_category": "destructive", "confidence": 0.9},
+			}},
+		})
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		model, escalation string
+		want              []string // tiers recorded
+	}{
+		{"bad", "", []string{"fast"}},
+		{"good", "bad", []string{"fast", "strong"}},
+	}
+	for _, tt := range tests {
+		t.Setenv("HOME", t.TempDir())
+		t.Setenv("CCYOLO_API_KEY", "test")
+		cfg := config.Config{
+			Model:     tt.model,
+			Providers: map[string]config.ProviderConfig{"anthropic": {BaseURL: srv.URL}},
+		}
+		if tt.escalation != "" {
+			cfg.Escalation = &config.Escalation{Model: tt.escalation}
+		}
+		e := &Engine{Evaluators: []Evaluator{llm{}}}
+		in := hook.Input{ToolName: "Bash", ToolInput: map[string]interface{}{"command": "rm x"}}
+		if _, err := e.Evaluate(context.Background(), Request{Input: in, Config: cfg}); err == nil {
+			t.Errorf("model %s, escalation %q: want error", tt.model, tt.escalation)
+		}
+
+		records, err := usage.Load(time.Time{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var tiers []string
+		for _, r := range records {
+			if r.Input != 100 {
+				t.Errorf("recorded %d input tokens, want 100", r.Input)
+			}
+			tiers = append(tiers, r.Tier)
+		}
+		if !reflect.DeepEqual(tiers, tt.want) {
+			t.Errorf("model %s, escalation %q: recorded %v, want %v", tt.model, tt.escalation, tiers, tt.want)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package hook
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/9roads/ccyolo/internal/tools"
+)
+
+// Input is the JSON payload Claude Code sends to hook commands on stdin.
+// PreToolUse and PermissionRequest share it; PermissionRequest adds the
+// "always allow" rules Claude Code would offer the user.
+type Input struct {
+	SessionID      string                 `json:"session_id"`
+	TranscriptPath string                 `json:"transcript_path"`
+	Cwd            string                 `json:"cwd"`
+	PermissionMode string                 `json:"permission_mode"`
+	HookEventName  string                 `json:"hook_event_name"`
+	ToolName       string                 `json:"tool_name"`
+	ToolInput      map[string]interface{} `json:"tool_input"`
+	ToolUseID      string                 `json:"tool_use_id"`
+
+	PermissionSuggestions []json.RawMessage `json:"permission_suggestions,omitempty"`
+}
+
+// Hook events ccyolo handles and can be registered under. PreToolUse runs
+// before every tool call; PermissionRequest only when Claude Code would
+// prompt the user.
+const (
+	EventPreToolUse        = "PreToolUse"
+	EventPermissionRequest = "PermissionRequest"
+)
+
+// Permission modes reported by Claude Code
+const (
+	ModeDefault           = "default"
+	ModePlan              = "plan"
+	ModeAcceptEdits       = "acceptEdits"
+	ModeBypassPermissions = "bypassPermissions"
+)
+
+// ReadOnly reports whether the tool only reads. In plan mode nothing else
+// is approved.
+func (in Input) ReadOnly() bool {
+	t, _ := tools.Lookup(in.ToolName)
+	return t.ReadOnly
+}
+
+// Edit reports whether the tool edits files, which Claude Code accepts on
+// its own in acceptEdits mode.
+func (in Input) Edit() bool {
+	t, _ := tools.Lookup(in.ToolName)
+	return t.Edit
+}
+
+// MCPTool splits an MCP tool name, mcp__<server>__<tool>.
+func (in Input) MCPTool() (server, tool string, ok bool) {
+	rest, ok := strings.CutPrefix(in.ToolName, "mcp__")
+	if !ok {
+		return "", "", false
+	}
+	server, tool, ok = strings.Cut(rest, "__")
+	return server, tool, ok && server != "" && tool != ""
+}
@@ -0,0 +1,98 @@
+package paths
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Normalize resolves a tool's path argument to the file it really refers to:
+// "~" and environment variables are expanded, relative paths are joined to
+// cwd, the result is cleaned and symlinks are resolved as far as the path
+// exists.
+func Normalize(p, cwd string) string {
+	if p == "" {
+		return ""
+	}
+
+	if p == "~" || strings.HasPrefix(p, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			p = home + p[1:]
+		}
+	}
+	p = os.ExpandEnv(p)
+
+	if !filepath.IsAbs(p) && cwd != "" {
+		p = filepath.Join(cwd, p)
+	}
+	p = filepath.Clean(p)
+
+	if !filepath.IsAbs(p) {
+		return p
+	}
+	return resolve(p)
+}
+
+// resolve follows symlinks in the longest existing prefix of an absolute
+// path, so a new file under a symlinked directory still resolves.
+func resolve(p string) string {
+	if real, err := filepath.EvalSymlinks(p); err == nil {
+		return real
+	}
+	parent := filepath.Dir(p)
+	if parent == p {
+		return p
+	}
+	return filepath.Join(resolve(parent), filepath.Base(p))
+}
+
+// Within reports whether path is root or inside it. Both must be normalized.
+func Within(path, root string) bool {
+	if root == "" || path == "" {
+		return false
+	}
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return false
+	}
+	return rel == "." || rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// ProjectRoot returns the normalized root of the project containing cwd:
+// the nearest directory with a .git entry, or cwd itself.
+func ProjectRoot(cwd string) string {
+	if cwd == "" {
+		return ""
+	}
+	cwd = Normalize(cwd, "")
+
+	for dir := cwd; ; {
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return cwd
+		}
+		dir = parent
+	}
+}
+
+// GitIgnored reports whether git would ignore path, which must be absolute.
+// Paths outside a git repository, or with git unavailable, are not ignored.
+func GitIgnored(path string) bool {
+	dir := filepath.Dir(path)
+	for {
+		if _, err := os.Stat(dir); err == nil {
+			break
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return false
+		}
+		dir = parent
+	}
+	cmd := exec.Command("git", "-C", dir, "check-ignore", "-q", path)
+	return cmd.Run() == nil
+}
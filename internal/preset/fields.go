@@ -0,0 +1,142 @@
+package preset
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/9roads/ccyolo/internal/hook"
+	"github.com/9roads/ccyolo/internal/paths"
+	"github.com/9roads/ccyolo/internal/shell"
+	"github.com/9roads/ccyolo/internal/tools"
+	"github.com/9roads/ccyolo/internal/urls"
+)
+
+// target is the tool call a rule is checked against. For Bash, command is
+// the simple command being checked, or nil for the whole command line.
+type target struct {
+	in      hook.Input
+	command *shell.Command
+}
+
+// pathFields are tool_input fields holding a file or directory path
+var pathFields = map[string]bool{
+	"file_path": true, "path": true, "notebook_path": true,
+}
+
+// subjects returns the values of a field to match against. Fields are
+// "tool_name", "cwd", "permission_mode" or "tool_input.<key>" (dots reach
+// into nested objects). An empty field means the tool's main field.
+func (t target) subjects(field string) []subject {
+	if field == "" {
+		return t.defaultSubjects()
+	}
+	if field == "tool_input.command" && t.in.ToolName == "Bash" {
+		return t.commandSubjects()
+	}
+
+	var value interface{}
+	key := field
+	switch field {
+	case "tool_name":
+		value = t.in.ToolName
+	case "cwd":
+		value = t.in.Cwd
+	case "permission_mode":
+		value = permissionMode(t.in)
+	default:
+		var ok bool
+		key, ok = strings.CutPrefix(field, "tool_input.")
+		if !ok {
+			return nil
+		}
+		value = lookup(t.in.ToolInput, key)
+	}
+
+	isPath := pathFields[key[strings.LastIndex(key, ".")+1:]]
+	var subjects []subject
+	for _, v := range flatten(value) {
+		if isPath {
+			subjects = append(subjects, pathSubject(v, t.in.Cwd))
+		} else {
+			subjects = append(subjects, subject{value: v})
+		}
+	}
+	return subjects
+}
+
+func (t target) commandSubjects() []subject {
+	if t.command != nil {
+		return []subject{{value: t.command.String(), args: t.command.Args}}
+	}
+	command, _ := t.in.ToolInput["command"].(string)
+	return []subject{{value: command}}
+}
+
+// defaultSubjects returns the tool's main field as declared in the tool
+// registry: the command for Bash, the path for file tools, the normalized URL
+// for WebFetch, and an empty value for everything else.
+func (t target) defaultSubjects() []subject {
+	tool, _ := tools.Lookup(t.in.ToolName)
+	switch {
+	case tool.Command != "":
+		return t.commandSubjects()
+	case tool.URL != "":
+		raw, _ := t.in.ToolInput[tool.URL].(string)
+		if u, err := urls.Normalize(raw); err == nil {
+			raw = u.String()
+		}
+		return []subject{{value: raw}}
+	}
+	if path, ok := tool.Path(t.in.ToolInput, t.in.Cwd); ok {
+		return []subject{pathSubject(path, t.in.Cwd)}
+	}
+	return []subject{{}}
+}
+
+// pathSubject normalizes a tool's path against the session cwd, so that
+// "../../etc/passwd", "~/.bashrc" and symlinks out of the repo match rules
+// by where they really point.
+func pathSubject(path, cwd string) subject {
+	path = paths.Normalize(path, cwd)
+	return subject{
+		value:     path,
+		isPath:    true,
+		inProject: paths.Within(path, paths.ProjectRoot(cwd)),
+	}
+}
+
+// lookup follows a dotted key into nested objects.
+func lookup(input map[string]interface{}, key string) interface{} {
+	var value interface{} = input
+	for _, part := range strings.Split(key, ".") {
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		value = obj[part]
+	}
+	return value
+}
+
+// flatten turns a JSON value into the strings to match: arrays give one
+// per element, objects are matched as JSON.
+func flatten(value interface{}) []string {
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case string:
+		return []string{v}
+	case []interface{}:
+		var values []string
+		for _, item := range v {
+			values = append(values, flatten(item)...)
+		}
+		return values
+	case map[string]interface{}:
+		data, _ := json.Marshal(v)
+		return []string{string(data)}
+	default:
+		return []string{fmt.Sprint(v)}
+	}
+}
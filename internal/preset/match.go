@@ -0,0 +1,169 @@
+package preset
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// Pattern types for Rule.Match
+const (
+	MatchSimple = ""      // "*", "prefix*", "*suffix", "*contains*" or exact
+	MatchGlob   = "glob"  // path globs, "**" spans directories
+	MatchRegex  = "regex" // Go regexp; anchor it with ^ and $ yourself
+	MatchArgv   = "argv"  // token-wise match of a command's words
+)
+
+// subject is a value a rule is matched against. args holds the shell words
+// of a Bash command, for argv patterns; paths are normalized and know
+// whether they are inside the project root.
+type subject struct {
+	value     string
+	args      []string
+	isPath    bool
+	inProject bool
+}
+
+type matcher interface {
+	match(s subject) bool
+}
+
+func compileMatcher(kind, pattern string) (matcher, error) {
+	switch kind {
+	case MatchSimple:
+		return simpleMatcher(pattern), nil
+	case MatchGlob:
+		if _, err := path.Match(strings.ReplaceAll(pattern, "**", "*"), ""); err != nil {
+			return nil, fmt.Errorf("glob %q: %w", pattern, err)
+		}
+		return globMatcher(strings.Split(pattern, "/")), nil
+	case MatchRegex:
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("regex %q: %w", pattern, err)
+		}
+		return regexMatcher{re}, nil
+	case MatchArgv:
+		tokens := strings.Fields(pattern)
+		for _, t := range tokens {
+			if _, err := path.Match(t, ""); err != nil && !argvPlaceholders[t] {
+				return nil, fmt.Errorf("argv %q: %w", pattern, err)
+			}
+		}
+		return argvMatcher(tokens), nil
+	}
+	return nil, fmt.Errorf("unknown match type %q", kind)
+}
+
+type simpleMatcher string
+
+func (m simpleMatcher) match(s subject) bool {
+	return MatchPattern(s.value, string(m))
+}
+
+type regexMatcher struct {
+	re *regexp.Regexp
+}
+
+func (m regexMatcher) match(s subject) bool {
+	return m.re.MatchString(s.value)
+}
+
+// globMatcher holds the "/"-separated segments of a glob pattern.
+type globMatcher []string
+
+func (m globMatcher) match(s subject) bool {
+	return matchSegments(m, strings.Split(s.value, "/"))
+}
+
+func matchSegments(pattern, segments []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			// "**" matches zero or more whole segments
+			for i := 0; i <= len(segments); i++ {
+				if matchSegments(pattern[1:], segments[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(segments) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], segments[0]); !ok {
+			return false
+		}
+		pattern, segments = pattern[1:], segments[1:]
+	}
+	return len(segments) == 0
+}
+
+// argv placeholders:
+//
+//	{flag}  one word starting with "-"     {flags} zero or more of them
+//	{arg}   one word not starting with "-" {args}  zero or more of them
+//	{any}   any one word                   {...}   any number of words
+//
+// Other words are matched with path.Match, so "--output=*" works. The
+// program name also matches by base name: "git" matches "/usr/bin/git".
+var argvPlaceholders = map[string]bool{
+	"{flag}": true, "{flags}": true, "{arg}": true, "{args}": true, "{any}": true, "{...}": true,
+}
+
+type argvMatcher []string
+
+func (m argvMatcher) match(s subject) bool {
+	if len(s.args) == 0 || len(m) == 0 {
+		return false
+	}
+	args := s.args
+	if !argvPlaceholders[m[0]] && !strings.Contains(m[0], "/") {
+		args = append([]string{path.Base(args[0])}, args[1:]...)
+	}
+	return matchArgv(m, args)
+}
+
+func matchArgv(pattern, args []string) bool {
+	if len(pattern) == 0 {
+		return len(args) == 0
+	}
+
+	isFlag := func(a string) bool { return len(a) > 1 && a[0] == '-' }
+
+	switch p := pattern[0]; p {
+	case "{...}", "{flags}", "{args}":
+		for i := 0; i <= len(args); i++ {
+			if matchArgv(pattern[1:], args[i:]) {
+				return true
+			}
+			if i == len(args) {
+				break
+			}
+			if p == "{flags}" && !isFlag(args[i]) || p == "{args}" && isFlag(args[i]) {
+				break
+			}
+		}
+		return false
+	}
+
+	if len(args) == 0 {
+		return false
+	}
+	switch p := pattern[0]; p {
+	case "{any}":
+	case "{flag}":
+		if !isFlag(args[0]) {
+			return false
+		}
+	case "{arg}":
+		if isFlag(args[0]) {
+			return false
+		}
+	default:
+		if ok, _ := path.Match(p, args[0]); !ok {
+			return false
+		}
+	}
+	return matchArgv(pattern[1:], args[1:])
+}
@@ -0,0 +1,115 @@
+package preset
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/9roads/ccyolo/internal/hook"
+)
+
+// MCPPolicy decides calls to MCP tools, which Claude Code names
+// mcp__<server>__<tool>. Empty actions leave the call to the next step.
+type MCPPolicy struct {
+	Default          Action                     `json:",omitempty"` // servers without their own default
+	ReadOnly         Action                     `json:",omitempty"` // read-style tools on any server
+	ReadOnlyPrefixes []string                   `json:",omitempty"` // default: list_, get_, search_, read_
+	Servers          map[string]MCPServerPolicy `json:",omitempty"`
+}
+
+type MCPServerPolicy struct {
+	Default  Action            `json:",omitempty"`
+	ReadOnly Action            `json:",omitempty"`
+	Tools    map[string]Action `json:",omitempty"` // tool name or simple pattern ("delete_*")
+}
+
+// validate checks the policy's actions.
+func (m MCPPolicy) validate() error {
+	if err := checkAction("MCP: Default", m.Default); err != nil {
+		return err
+	}
+	if err := checkAction("MCP: ReadOnly", m.ReadOnly); err != nil {
+		return err
+	}
+	for name, sp := range m.Servers {
+		if err := checkAction("MCP: "+name+": Default", sp.Default); err != nil {
+			return err
+		}
+		if err := checkAction("MCP: "+name+": ReadOnly", sp.ReadOnly); err != nil {
+			return err
+		}
+		for tool, action := range sp.Tools {
+			if err := checkAction("MCP: "+name+": "+tool, action); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+var defaultReadOnlyPrefixes = []string{"list_", "get_", "search_", "read_"}
+
+// IsReadOnly reports whether an MCP tool name looks like it only reads.
+func (m MCPPolicy) IsReadOnly(tool string) bool {
+	prefixes := m.ReadOnlyPrefixes
+	if len(prefixes) == 0 {
+		prefixes = defaultReadOnlyPrefixes
+	}
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(tool, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Check returns the policy's decision for an MCP tool call, most specific
+// setting first: the tool, then read-only handling, then the defaults.
+func (m MCPPolicy) Check(in hook.Input) *RuleMatch {
+	server, tool, ok := in.MCPTool()
+	if !ok {
+		return nil
+	}
+	sp := m.Servers[server]
+	readOnly := m.IsReadOnly(tool)
+
+	decide := func(action Action, id, reason string) *RuleMatch {
+		if action == "" {
+			return nil
+		}
+		return &RuleMatch{Action: action, Rule: Rule{ID: id, Action: action, Tool: in.ToolName, Reason: reason}}
+	}
+
+	if action, ok := sp.Tools[tool]; ok {
+		return decide(action, "mcp:"+server+"/"+tool, "")
+	}
+	// Sorted, so ties between equally strict patterns always go the same way
+	patterns := make([]string, 0, len(sp.Tools))
+	for pattern := range sp.Tools {
+		patterns = append(patterns, pattern)
+	}
+	sort.Strings(patterns)
+	var byPattern *RuleMatch
+	for _, pattern := range patterns {
+		action := sp.Tools[pattern]
+		if MatchPattern(tool, pattern) && (byPattern == nil || strictness(action) > strictness(byPattern.Action)) {
+			byPattern = decide(action, "mcp:"+server+"/"+pattern, "")
+		}
+	}
+	if byPattern != nil {
+		return byPattern
+	}
+
+	if readOnly {
+		if sp.ReadOnly != "" {
+			return decide(sp.ReadOnly, "mcp:"+server+"/read-only", "read-only MCP tool")
+		}
+		if m.ReadOnly != "" {
+			return decide(m.ReadOnly, "mcp:read-only", "read-only MCP tool")
+		}
+	}
+
+	if sp.Default != "" {
+		return decide(sp.Default, "mcp:"+server, "")
+	}
+	return decide(m.Default, "mcp:default", "")
+}
@@ -0,0 +1,69 @@
+package preset
+
+import (
+	"testing"
+
+	"github.com/9roads/ccyolo/internal/hook"
+)
+
+func TestMCPPolicyCheck(t *testing.T) {
+	m := MCPPolicy{
+		Default:  Ask,
+		ReadOnly: Allow,
+		Servers: map[string]MCPServerPolicy{
+			"db": {
+				Default: Deny,
+				Tools: map[string]Action{
+					"query":    Allow,
+					"drop_*":   Deny,
+					"*_table":  Ask,
+					"get_*":    Ask,
+					"*_schema": Ask,
+				},
+			},
+		},
+	}
+
+	tests := []struct {
+		tool   string
+		action Action
+		id     string
+	}{
+		{"mcp__db__query", Allow, "mcp:db/query"},
+		{"mcp__db__drop_table", Deny, "mcp:db/drop_*"},
+		{"mcp__db__get_schema", Ask, "mcp:db/*_schema"}, // ties go to the first pattern in order
+		{"mcp__db__list_rows", Allow, "mcp:read-only"},
+		{"mcp__db__insert", Deny, "mcp:db"},
+		{"mcp__docs__search_docs", Allow, "mcp:read-only"},
+		{"mcp__docs__write", Ask, "mcp:default"},
+	}
+	for _, tt := range tests {
+		for i := 0; i < 20; i++ {
+			m := m.Check(hook.Input{ToolName: tt.tool})
+			if m == nil || m.Action != tt.action || m.Rule.ID != tt.id {
+				t.Errorf("Check(%s) = %+v, want %s by %s", tt.tool, m, tt.action, tt.id)
+				break
+			}
+		}
+	}
+}
+
+func TestCompileMCPActions(t *testing.T) {
+	for _, tt := range []struct {
+		mcp MCPPolicy
+		ok  bool
+	}{
+		{MCPPolicy{Default: Ask, ReadOnly: Allow}, true},
+		{MCPPolicy{Servers: map[string]MCPServerPolicy{"db": {Default: Deny, Tools: map[string]Action{"query": Allow}}}}, true},
+		{MCPPolicy{Default: "block"}, false},
+		{MCPPolicy{ReadOnly: "Allow"}, false},
+		{MCPPolicy{Servers: map[string]MCPServerPolicy{"db": {Default: "Deny"}}}, false},
+		{MCPPolicy{Servers: map[string]MCPServerPolicy{"db": {ReadOnly: "yes"}}}, false},
+		{MCPPolicy{Servers: map[string]MCPServerPolicy{"db": {Tools: map[string]Action{"drop_*": "block"}}}}, false},
+	} {
+		p := Preset{MCP: &tt.mcp}
+		if err := p.Compile(); (err == nil) != tt.ok {
+			t.Errorf("Compile(%+v) = %v, want ok %v", tt.mcp, err, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package preset
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/9roads/ccyolo/internal/paths"
+	"github.com/9roads/ccyolo/internal/shell"
+)
+
+// NormalizeRule generalizes Bash commands for the decision cache, so calls
+// that differ only in their arguments share a decision. Rules are tried in
+// order on single simple commands and the first match decides; a command
+// no rule matches is cached verbatim.
+type NormalizeRule struct {
+	ID      string   `json:",omitempty"`
+	Match   string   // regular expression on the command line
+	Replace string   `json:",omitempty"` // generalized form, with $1 for groups; empty: never generalize
+	Keep    []string `json:",omitempty"` // arguments that keep the command verbatim (see KeepKinds)
+	re      *regexp.Regexp
+}
+
+// Argument kinds a rule can keep verbatim. Paths are resolved against the
+// session cwd.
+const (
+	KeepOutsideProject = "outside-project" // a path outside the project
+	KeepAbsolute       = "absolute"        // an absolute or ~ path
+	KeepHome           = "home"            // the home directory or a path in it, outside the project
+	KeepGlob           = "glob"            // a wildcard
+	KeepVariable       = "variable"        // a $ expansion
+)
+
+// KeepKinds lists the argument kinds for Keep.
+var KeepKinds = []string{KeepOutsideProject, KeepAbsolute, KeepHome, KeepGlob, KeepVariable}
+
+// DefaultNormalize applies to presets that don't set their own. Recursive
+// rm is never generalized: "rm -rf build" must not approve "rm -rf ~".
+var DefaultNormalize = []NormalizeRule{
+	{ID: "package-install", Match: `^(npm|yarn|pnpm)\s+(install|add|remove)\s+.+`, Replace: "$1 $2 *", Keep: []string{KeepOutsideProject}},
+	{ID: "pip-install", Match: `^pip3?\s+install\s+.+`, Replace: "pip install *", Keep: []string{KeepOutsideProject}},
+	{ID: "git-commit", Match: `^git\s+commit\s+.+`, Replace: "git commit *"},
+	{ID: "rm-recursive", Match: `^rm\s(.*\s)?(-[a-zA-Z]*[rR]|--recursive)`},
+	{ID: "rm-flags", Match: `^rm\s+-.*`, Replace: "rm -* *", Keep: []string{KeepOutsideProject, KeepVariable, KeepGlob}},
+	{ID: "rm", Match: `^rm\s+.+`, Replace: "rm *", Keep: []string{KeepOutsideProject, KeepVariable, KeepGlob}},
+	{ID: "mkdir", Match: `^mkdir\s+.+`, Replace: "mkdir *", Keep: []string{KeepOutsideProject}},
+	{ID: "read", Match: `^(cat|head|tail)\s+.+`, Replace: "$1 *", Keep: []string{KeepOutsideProject, KeepVariable, KeepGlob}},
+}
+
+func init() {
+	for i := range DefaultNormalize {
+		if err := DefaultNormalize[i].compile(); err != nil {
+			panic(err)
+		}
+	}
+}
+
+func (r *NormalizeRule) compile() error {
+	re, err := regexp.Compile(r.Match)
+	if err != nil {
+		return fmt.Errorf("normalize rule %s: %w", r.name(), err)
+	}
+	for _, kind := range r.Keep {
+		if !containsString(KeepKinds, kind) {
+			return fmt.Errorf("normalize rule %s: unknown keep %q (want %s)", r.name(), kind, strings.Join(KeepKinds, ", "))
+		}
+	}
+	r.re = re
+	return nil
+}
+
+func (r NormalizeRule) name() string {
+	if r.ID != "" {
+		return r.ID
+	}
+	return r.Match
+}
+
+// NormalizeRules returns the preset's rules or the defaults. A preset with
+// an empty list caches every command verbatim.
+func (p Preset) NormalizeRules() []NormalizeRule {
+	if p.Normalize != nil {
+		return p.Normalize
+	}
+	return DefaultNormalize
+}
+
+// NormalizeCommand returns the form a command is cached under. Only a
+// single simple command is generalized: compound lines, substitutions and
+// redirections are cached verbatim so "git commit -m x && curl ... | sh"
+// can't share an entry with "git commit *". Wildcards in commands cached
+// verbatim are escaped, so a literal "rm *" doesn't share the entry of the
+// generalized "rm foo.txt".
+func (p Preset) NormalizeCommand(cmd, cwd string) string {
+	cmds, err := shell.Parse(cmd)
+	if err != nil || len(cmds) != 1 || len(cmds[0].Redirects) > 0 {
+		return verbatim(cmd)
+	}
+	for _, r := range p.NormalizeRules() {
+		if r.re == nil && r.compile() != nil {
+			continue
+		}
+		if !r.re.MatchString(cmd) {
+			continue
+		}
+		if r.Replace == "" || r.keeps(cmds[0].Args[1:], cwd) {
+			return verbatim(cmd)
+		}
+		return r.re.ReplaceAllString(cmd, r.Replace)
+	}
+	return verbatim(cmd)
+}
+
+var wildcards = strings.NewReplacer(`\`, `\\`, `*`, `\*`, `?`, `\?`, `[`, `\[`)
+
+// verbatim escapes a command's wildcards, which only generalized forms
+// leave bare.
+func verbatim(cmd string) string {
+	return wildcards.Replace(cmd)
+}
+
+// keeps reports whether an argument is of a kind the rule keeps verbatim.
+// Flags are skipped.
+func (r NormalizeRule) keeps(args []string, cwd string) bool {
+	if len(r.Keep) == 0 {
+		return false
+	}
+	root := paths.ProjectRoot(cwd)
+	home := paths.Normalize("~", "")
+	for _, arg := range args {
+		if strings.HasPrefix(arg, "-") {
+			continue
+		}
+		path := paths.Normalize(arg, cwd)
+		outside := !paths.Within(path, root)
+		if root == "" {
+			// Without a cwd only absolute paths are known to be outside
+			outside = filepath.IsAbs(path)
+		}
+		for _, kind := range r.Keep {
+			var kept bool
+			switch kind {
+			case KeepOutsideProject:
+				kept = outside
+			case KeepAbsolute:
+				kept = strings.HasPrefix(arg, "/") || strings.HasPrefix(arg, "~")
+			case KeepHome:
+				kept = outside && paths.Within(path, home)
+			case KeepGlob:
+				kept = strings.ContainsAny(arg, "*?[")
+			case KeepVariable:
+				kept = strings.Contains(arg, "$")
+			}
+			if kept {
+				return true
+			}
+		}
+	}
+	return false
+}
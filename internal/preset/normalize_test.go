@@ -0,0 +1,158 @@
+package preset
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// project makes a git project under a temporary home and returns its root.
+func project(t *testing.T) (home, root string) {
+	home, _ = filepath.EvalSymlinks(t.TempDir())
+	t.Setenv("HOME", home)
+	root = filepath.Join(home, "proj")
+	if err := os.MkdirAll(filepath.Join(root, ".git"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return home, root
+}
+
+func TestNormalizeCommandDefaults(t *testing.T) {
+	_, root := project(t)
+	var p Preset
+
+	tests := []struct {
+		cmd, want string
+	}{
+		{"npm install express", "npm install *"},
+		{"yarn add react", "yarn add *"},
+		{"pip3 install requests", "pip install *"},
+		{"git commit -m 'fix it'", "git commit *"},
+		{"rm foo.txt", "rm *"},
+		{"rm -f foo.txt", "rm -* *"},
+		{"mkdir -p a/b", "mkdir *"},
+		{"cat README.md", "cat *"},
+		{"tail -n 5 log.txt", "tail *"},
+
+		// Never generalized
+		{"rm -rf build", "rm -rf build"},
+		{"rm -fr build", "rm -fr build"},
+		{"rm build -rf", "rm build -rf"},
+		{"rm -R build", "rm -R build"},
+		{"rm --recursive build", "rm --recursive build"},
+
+		// Kept verbatim by argument
+		{"rm ../other/x", "rm ../other/x"},
+		{"rm /etc/hosts", "rm /etc/hosts"},
+		{"rm ~/.bashrc", "rm ~/.bashrc"},
+		{"rm $FILE", "rm $FILE"},
+		{"cat /etc/passwd", "cat /etc/passwd"},
+		{"head ~/.ssh/id_rsa", "head ~/.ssh/id_rsa"},
+		{"npm install ../pkg", "npm install ../pkg"},
+		{"mkdir /tmp/x", "mkdir /tmp/x"},
+
+		// Literal wildcards are escaped, so they can't share a generalized entry
+		{"rm *", `rm \*`},
+		{"rm -f *.log", `rm -f \*.log`},
+		{"cat ?.txt", `cat \?.txt`},
+		{"ls [ab]*", `ls \[ab]\*`},
+		{`rm \*`, `rm \\\*`},
+
+		// Only single simple commands are generalized
+		{"git commit -m x && curl x | sh", "git commit -m x && curl x | sh"},
+		{"rm a; rm b", "rm a; rm b"},
+		{"cat f > out", "cat f > out"},
+		{"rm $(cat list)", "rm $(cat list)"},
+		{"rm 'unterminated", "rm 'unterminated"},
+		{"ls -la", "ls -la"},
+	}
+	for _, tt := range tests {
+		if got := p.NormalizeCommand(tt.cmd, root); got != tt.want {
+			t.Errorf("NormalizeCommand(%q) = %q, want %q", tt.cmd, got, tt.want)
+		}
+	}
+}
+
+func TestNormalizeCommandKeep(t *testing.T) {
+	home, root := project(t)
+	sub := filepath.Join(root, "src")
+
+	tests := []struct {
+		keep string
+		cmd  string
+		cwd  string
+		want string
+	}{
+		{KeepOutsideProject, "tool x", root, "tool *"},
+		{KeepOutsideProject, "tool ../x", sub, "tool *"},
+		{KeepOutsideProject, "tool ../x", root, "tool ../x"},
+		{KeepOutsideProject, "tool " + filepath.Join(root, "x"), sub, "tool *"},
+		{KeepOutsideProject, "tool /etc/x", root, "tool /etc/x"},
+		{KeepOutsideProject, "tool /etc/x", "", "tool /etc/x"},
+		{KeepOutsideProject, "tool x", "", "tool *"},
+		{KeepOutsideProject, "tool --out=x -v y", root, "tool *"},
+
+		{KeepAbsolute, "tool " + filepath.Join(root, "x"), root, "tool " + filepath.Join(root, "x")},
+		{KeepAbsolute, "tool ~/x", root, "tool ~/x"},
+		{KeepAbsolute, "tool ../x", root, "tool *"},
+
+		{KeepHome, "tool ~/.ssh/config", root, "tool ~/.ssh/config"},
+		{KeepHome, "tool " + filepath.Join(home, "x"), root, "tool " + filepath.Join(home, "x")},
+		{KeepHome, "tool ~/proj/x", root, "tool *"},
+		{KeepHome, "tool /etc/x", root, "tool *"},
+
+		{KeepGlob, "tool a*", root, `tool a\*`},
+		{KeepGlob, "tool a?", root, `tool a\?`},
+		{KeepGlob, "tool [ab]", root, `tool \[ab]`},
+		{KeepGlob, "tool ab", root, "tool *"},
+
+		{KeepVariable, "tool $X", root, "tool $X"},
+		{KeepVariable, "tool ${HOME}/x", root, "tool ${HOME}/x"},
+		{KeepVariable, "tool x", root, "tool *"},
+	}
+	for _, tt := range tests {
+		p := Preset{Normalize: []NormalizeRule{{Match: `^tool\s+.+`, Replace: "tool *", Keep: []string{tt.keep}}}}
+		if err := p.Compile(); err != nil {
+			t.Fatal(err)
+		}
+		if got := p.NormalizeCommand(tt.cmd, tt.cwd); got != tt.want {
+			t.Errorf("keep %s: NormalizeCommand(%q, %q) = %q, want %q", tt.keep, tt.cmd, tt.cwd, got, tt.want)
+		}
+	}
+}
+
+func TestNormalizeRules(t *testing.T) {
+	// Rules apply in order and the first match wins
+	p := Preset{Normalize: []NormalizeRule{
+		{ID: "never", Match: `^make\s+deploy`},
+		{ID: "make", Match: `^make\s+(\S+).*`, Replace: "make $1 *"},
+	}}
+	if err := p.Compile(); err != nil {
+		t.Fatal(err)
+	}
+	for cmd, want := range map[string]string{
+		"make deploy prod": "make deploy prod",
+		"make test -j4":    "make test *",
+		"rm foo":           "rm foo", // the defaults don't apply
+	} {
+		if got := p.NormalizeCommand(cmd, ""); got != want {
+			t.Errorf("NormalizeCommand(%q) = %q, want %q", cmd, got, want)
+		}
+	}
+
+	// An empty list caches every command verbatim
+	empty := Preset{Normalize: []NormalizeRule{}}
+	if got := empty.NormalizeCommand("npm install x", ""); got != "npm install x" {
+		t.Errorf("empty rules: got %q", got)
+	}
+
+	for _, bad := range []NormalizeRule{
+		{Match: `^(rm`},
+		{Match: `^rm`, Replace: "rm *", Keep: []string{"elsewhere"}},
+	} {
+		p := Preset{Normalize: []NormalizeRule{bad}}
+		if err := p.Compile(); err == nil {
+			t.Errorf("Compile(%+v): want error", bad)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package preset
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGet(t *testing.T) {
+	home, root := project(t)
+	dir := filepath.Join(home, ".ccyolo", "presets")
+	projectDir := filepath.Join(root, ".ccyolo", "presets")
+	for _, d := range []string{dir, projectDir} {
+		if err := os.MkdirAll(d, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write := func(dir, name, data string) {
+		if err := os.WriteFile(filepath.Join(dir, name+".json"), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(dir, "mine", `{"Description": "home"}`)
+	write(projectDir, "mine", `{"Description": "project"}`)
+	write(dir, "broken", `{"Rules": [`)
+	write(dir, "invalid", `{"Rules": [{"Tool": "Bash", "Pattern": "ls*"}]}`)
+
+	if p, err := Get("mine", projectDir); err != nil || p.Description != "project" {
+		t.Errorf("Get(mine, project) = %q, %v; want the project preset", p.Description, err)
+	}
+	if p, err := Get("mine"); err != nil || p.Description != "home" {
+		t.Errorf("Get(mine) = %q, %v; want the home preset", p.Description, err)
+	}
+	if p, err := Get("strict"); err != nil || p.Name != "strict" {
+		t.Errorf("Get(strict) = %q, %v", p.Name, err)
+	}
+	if p, err := Get("unknown"); err != nil || p.Name != "balanced" {
+		t.Errorf("Get(unknown) = %q, %v; want balanced", p.Name, err)
+	}
+	for _, name := range []string{"broken", "invalid"} {
+		if p, err := Get(name); err == nil {
+			t.Errorf("Get(%s) = %q, want error", name, p.Name)
+		}
+	}
+}
+
+func TestCompilePresetSettings(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		p    Preset
+		ok   bool
+	}{
+		{"default", Preset{}, true},
+		{"secrets ask", Preset{Secrets: Ask}, true},
+		{"secrets off", Preset{Secrets: "off"}, false},
+		{"unknown tools deny", Preset{UnknownTools: Deny}, true},
+		{"unknown tools block", Preset{UnknownTools: "block"}, false},
+		{"thresholds", Preset{Thresholds: &Thresholds{Allow: 20, Deny: 101}}, true},
+		{"thresholds out of order", Preset{Thresholds: &Thresholds{Allow: 80, Deny: 30}}, false},
+		{"thresholds negative", Preset{Thresholds: &Thresholds{Allow: -1, Deny: 80}}, false},
+		{"thresholds allow everything", Preset{Thresholds: &Thresholds{Allow: 101, Deny: 200}}, false},
+	} {
+		if err := tt.p.Compile(); (err == nil) != tt.ok {
+			t.Errorf("%s: Compile = %v, want ok %v", tt.name, err, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package preset
+
+import "fmt"
+
+// Thresholds map the model's risk score (0-100) to an action: scores below
+// Allow are allowed, scores from Deny up are denied, the rest are asked
+// about. Set Deny above 100 to never deny.
+type Thresholds struct {
+	Allow int
+	Deny  int
+}
+
+// DefaultThresholds apply to presets that don't set their own.
+var DefaultThresholds = Thresholds{Allow: 30, Deny: 80}
+
+// Action returns the action for a risk score.
+func (t Thresholds) Action(score int) Action {
+	switch {
+	case score >= t.Deny:
+		return Deny
+	case score < t.Allow:
+		return Allow
+	}
+	return Ask
+}
+
+// Validate checks that the ranges are in order and a score of 100 is never
+// allowed.
+func (t Thresholds) Validate() error {
+	if t.Allow < 0 || t.Allow > 100 || t.Allow > t.Deny {
+		return fmt.Errorf("thresholds: want 0 <= Allow <= Deny and Allow <= 100, got Allow %d, Deny %d", t.Allow, t.Deny)
+	}
+	return nil
+}
+
+// RiskThresholds returns the preset's thresholds or the defaults.
+func (p Preset) RiskThresholds() Thresholds {
+	if p.Thresholds != nil {
+		return *p.Thresholds
+	}
+	return DefaultThresholds
+}
@@ -0,0 +1,451 @@
+package preset
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/9roads/ccyolo/internal/hook"
+	"github.com/9roads/ccyolo/internal/shell"
+	"github.com/9roads/ccyolo/internal/tools"
+)
+
+type Action string
+
+const (
+	Allow Action = "allow"
+	Ask   Action = "ask"
+	Deny  Action = "deny"
+)
+
+// strictness orders actions so the strictest result can win.
+func strictness(a Action) int {
+	switch a {
+	case Deny:
+		return 3
+	case Ask:
+		return 2
+	case Allow:
+		return 1
+	}
+	return 0
+}
+
+type Rule struct {
+	ID          string `json:",omitempty"`
+	Description string `json:",omitempty"`
+	Action      Action `json:",omitempty"` // allow, ask or deny
+	Tool        string
+	Field       string `json:",omitempty"` // field Pattern applies to, e.g. "tool_input.url" (default: the tool's main field)
+	Pattern     string
+	Match       string      `json:",omitempty"` // pattern type: "" (simple), glob, regex or argv
+	Except      []string    `json:",omitempty"` // patterns of the same type that exempt a value
+	InProject   *bool       `json:",omitempty"` // only paths inside (true) or outside (false) the project root
+	All         []Condition `json:",omitempty"` // further conditions that must all match
+	Any         []Condition `json:",omitempty"` // further conditions of which at least one must match
+	Reason      string      `json:",omitempty"` // shown to the user, and to Claude on deny
+	Modes       []string    `json:",omitempty"` // permission modes the rule applies in (default: all)
+	CacheTTL    int         `json:",omitempty"` // without an Action: seconds AI decisions on matching calls stay cached (-1: never)
+
+	cond Condition
+}
+
+// Condition matches one field of a tool call. A field with several values
+// (an array) matches if any of them does.
+type Condition struct {
+	Field     string `json:",omitempty"`
+	Pattern   string
+	Match     string   `json:",omitempty"`
+	Except    []string `json:",omitempty"`
+	InProject *bool    `json:",omitempty"`
+
+	pattern matcher
+	except  []matcher
+}
+
+func (c *Condition) compile() error {
+	m, err := compileMatcher(c.Match, c.Pattern)
+	if err != nil {
+		return err
+	}
+	c.pattern = m
+	c.except = nil
+	for _, e := range c.Except {
+		m, err := compileMatcher(c.Match, e)
+		if err != nil {
+			return fmt.Errorf("except: %w", err)
+		}
+		c.except = append(c.except, m)
+	}
+	return nil
+}
+
+func (c Condition) matches(t target) bool {
+	if c.pattern == nil {
+		// Not compiled: only simple patterns can be used as-is
+		if c.Match != MatchSimple {
+			return false
+		}
+		c.compile()
+	}
+	for _, s := range t.subjects(c.Field) {
+		if c.matchesSubject(s) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c Condition) matchesSubject(s subject) bool {
+	if c.InProject != nil && (!s.isPath || *c.InProject != s.inProject) {
+		return false
+	}
+	if !c.pattern.match(s) {
+		return false
+	}
+	for _, except := range c.except {
+		if except.match(s) {
+			return false
+		}
+	}
+	return true
+}
+
+// compile prepares the rule's patterns so they are parsed once per load.
+func (r *Rule) compile() error {
+	r.cond = Condition{
+		Field:     r.Field,
+		Pattern:   r.Pattern,
+		Match:     r.Match,
+		Except:    r.Except,
+		InProject: r.InProject,
+	}
+	if r.CacheTTL != 0 && r.Action != "" {
+		return fmt.Errorf("rule %s: CacheTTL only applies to rules without an Action", r.Name())
+	}
+	if err := r.cond.compile(); err != nil {
+		return fmt.Errorf("rule %s: %w", r.Name(), err)
+	}
+
+	r.All = append([]Condition(nil), r.All...)
+	r.Any = append([]Condition(nil), r.Any...)
+	for _, conds := range [][]Condition{r.All, r.Any} {
+		for i := range conds {
+			if err := conds[i].compile(); err != nil {
+				return fmt.Errorf("rule %s: %s: %w", r.Name(), conds[i].Field, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Compile prepares every rule of the preset, normalize rules included.
+// Presets returned by Get are already compiled.
+func (p *Preset) Compile() error {
+	for _, r := range p.Rules {
+		if r.ttlOnly() {
+			continue
+		}
+		if r.Action == "" {
+			return fmt.Errorf("rule %s: no Action (want allow, ask or deny)", r.Name())
+		}
+		if err := checkAction("rule "+r.Name()+": Action", r.Action); err != nil {
+			return err
+		}
+	}
+	if err := checkAction("Secrets", p.Secrets); err != nil {
+		return err
+	}
+	if err := checkAction("UnknownTools", p.UnknownTools); err != nil {
+		return err
+	}
+	if p.Thresholds != nil {
+		if err := p.Thresholds.Validate(); err != nil {
+			return err
+		}
+	}
+	if p.MCP != nil {
+		if err := p.MCP.validate(); err != nil {
+			return err
+		}
+	}
+	if p.Web != nil {
+		if err := p.Web.validate(); err != nil {
+			return err
+		}
+	}
+	for _, list := range []*[]Rule{&p.Rules, &p.AlwaysAllow, &p.AlwaysDeny} {
+		compiled := make([]Rule, len(*list))
+		copy(compiled, *list)
+		for i := range compiled {
+			if err := compiled[i].compile(); err != nil {
+				return err
+			}
+		}
+		*list = compiled
+	}
+	if p.Normalize != nil {
+		compiled := make([]NormalizeRule, len(p.Normalize))
+		copy(compiled, p.Normalize)
+		for i := range compiled {
+			if err := compiled[i].compile(); err != nil {
+				return err
+			}
+		}
+		p.Normalize = compiled
+	}
+	return nil
+}
+
+// checkAction rejects an action other than allow, ask, deny or empty, so
+// a typo fails at load instead of reaching the hook output.
+func checkAction(field string, a Action) error {
+	switch a {
+	case "", Allow, Ask, Deny:
+		return nil
+	}
+	return fmt.Errorf("%s: unknown action %q (want allow, ask or deny)", field, a)
+}
+
+// Name identifies a rule in hook reasons and logs.
+func (r Rule) Name() string {
+	if r.ID != "" {
+		return r.ID
+	}
+	return r.Tool + ":" + r.Pattern
+}
+
+func (r Rule) matches(t target) bool {
+	if r.Tool != "*" && r.Tool != t.in.ToolName {
+		return false
+	}
+	if len(r.Modes) > 0 && !containsString(r.Modes, permissionMode(t.in)) {
+		return false
+	}
+	if r.cond.pattern == nil {
+		r.compile()
+	}
+
+	// A rule made only of All/Any conditions has no main pattern
+	if r.Pattern != "" || len(r.All) == 0 && len(r.Any) == 0 {
+		if !r.cond.matches(t) {
+			return false
+		}
+	}
+
+	for _, c := range r.All {
+		if !c.matches(t) {
+			return false
+		}
+	}
+	if len(r.Any) == 0 {
+		return true
+	}
+	for _, c := range r.Any {
+		if c.matches(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// RuleMatch is the decision of the strictest rule that matched.
+type RuleMatch struct {
+	Action Action
+	Rule   Rule
+}
+
+// Reason describes the match for hook output and logs.
+func (m RuleMatch) Reason() string {
+	if m.Rule.Reason != "" {
+		return "rule " + m.Rule.Name() + ": " + m.Rule.Reason
+	}
+	return "rule " + m.Rule.Name()
+}
+
+// AllRules returns the preset's deciding rules with the legacy AlwaysAllow
+// and AlwaysDeny lists folded in. TTL rules are left out.
+func (p Preset) AllRules() []Rule {
+	rules := make([]Rule, 0, len(p.Rules)+len(p.AlwaysAllow)+len(p.AlwaysDeny))
+	for _, r := range p.Rules {
+		if r.ttlOnly() {
+			continue
+		}
+		if r.Action == "" {
+			// Not compiled: fail closed
+			r.Action = Ask
+		}
+		rules = append(rules, r)
+	}
+	for _, r := range p.AlwaysDeny {
+		r.Action = Deny
+		rules = append(rules, r)
+	}
+	for _, r := range p.AlwaysAllow {
+		r.Action = Allow
+		rules = append(rules, r)
+	}
+	return rules
+}
+
+func MatchPattern(value, pattern string) bool {
+	if pattern == "*" {
+		return true
+	}
+
+	// Handle *contains* pattern (wildcards on both ends)
+	if strings.HasPrefix(pattern, "*") && strings.HasSuffix(pattern, "*") {
+		middle := pattern[1 : len(pattern)-1]
+		return strings.Contains(value, middle)
+	}
+
+	// Handle prefix* pattern
+	if strings.HasSuffix(pattern, "*") {
+		prefix := pattern[:len(pattern)-1]
+		return strings.HasPrefix(value, prefix)
+	}
+
+	// Handle *suffix pattern
+	if strings.HasPrefix(pattern, "*") {
+		suffix := pattern[1:]
+		return strings.HasSuffix(value, suffix)
+	}
+
+	return value == pattern
+}
+
+// CheckRules returns the strictest matching rule, or nil if no rule decides.
+// MCP and web tools are also checked against the preset's MCP and web
+// policies.
+func CheckRules(in hook.Input, p Preset) *RuleMatch {
+	rules := p.AllRules()
+
+	if in.ToolName == "Bash" {
+		return checkBashRules(in, rules)
+	}
+
+	result := strictestMatch(rules, target{in: in})
+	if p.MCP != nil {
+		result = stricter(result, p.MCP.Check(in))
+	}
+	if p.Web != nil {
+		result = stricter(result, p.Web.Check(in))
+	}
+	if result == nil && p.UnknownTools != "" {
+		if _, ok := tools.Lookup(in.ToolName); !ok {
+			if _, _, mcp := in.MCPTool(); !mcp {
+				result = &RuleMatch{Action: p.UnknownTools, Rule: Rule{
+					ID: "unknown-tool", Action: p.UnknownTools, Tool: in.ToolName, Reason: "tool ccyolo doesn't know",
+				}}
+			}
+		}
+	}
+	return result
+}
+
+// checkBashRules evaluates every simple command of a Bash command line on
+// its own. The strictest result wins, and the line is only allowed when
+// every command is allowed.
+func checkBashRules(in hook.Input, rules []Rule) *RuleMatch {
+	command, _ := in.ToolInput["command"].(string)
+	cmds, err := shell.Parse(command)
+	if err != nil {
+		cmds = nil
+	}
+
+	// Deny and ask rules also see the whole line so patterns spanning a
+	// pipe still match. A pattern one command matches on its own is left
+	// to that command, where its Except patterns apply.
+	var spanning []Rule
+	for _, r := range rules {
+		if r.Action != Allow && !matchesAnyCommand(r, in, cmds) {
+			spanning = append(spanning, r)
+		}
+	}
+	result := strictestMatch(spanning, target{in: in})
+	if len(cmds) == 0 {
+		// Unparseable: never allow by rule
+		return result
+	}
+
+	unmatched := false
+	for i := range cmds {
+		m := strictestMatch(rules, target{in: in, command: &cmds[i]})
+		for _, path := range cmds[i].WrittenFiles() {
+			m = checkRedirect(m, rules, in, path)
+		}
+		if m == nil {
+			unmatched = true
+			continue
+		}
+		if result == nil || strictness(m.Action) > strictness(result.Action) {
+			result = m
+		}
+	}
+
+	if result != nil && result.Action == Allow && unmatched {
+		return nil
+	}
+	return result
+}
+
+// checkRedirect checks a file a command's output is redirected to as a
+// Write of that file. A rule allowing the command doesn't cover the write:
+// it stands only if a rule allows writing the file too, and a stricter
+// rule on the write wins.
+func checkRedirect(m *RuleMatch, rules []Rule, in hook.Input, path string) *RuleMatch {
+	write := hook.Input{ToolName: "Write", ToolInput: map[string]interface{}{"file_path": path}, Cwd: in.Cwd, PermissionMode: in.PermissionMode}
+	w := strictestMatch(rules, target{in: write})
+	switch {
+	case w == nil:
+		if m != nil && m.Action == Allow {
+			return nil
+		}
+	case w.Action != Allow && (m == nil || strictness(w.Action) > strictness(m.Action)):
+		return w
+	}
+	return m
+}
+
+// matchesAnyCommand reports whether a rule, exceptions aside, matches one
+// of the simple commands.
+func matchesAnyCommand(r Rule, in hook.Input, cmds []shell.Command) bool {
+	if r.cond.pattern == nil {
+		r.compile()
+	}
+	r.cond.except = nil
+	for i := range cmds {
+		if r.matches(target{in: in, command: &cmds[i]}) {
+			return true
+		}
+	}
+	return false
+}
+
+func strictestMatch(rules []Rule, t target) *RuleMatch {
+	var result *RuleMatch
+	for _, rule := range rules {
+		if !rule.matches(t) {
+			continue
+		}
+		if result == nil || strictness(rule.Action) > strictness(result.Action) {
+			result = &RuleMatch{Action: rule.Action, Rule: rule}
+		}
+	}
+	return result
+}
+
+func permissionMode(in hook.Input) string {
+	if in.PermissionMode == "" {
+		return hook.ModeDefault
+	}
+	return in.PermissionMode
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
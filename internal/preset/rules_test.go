@@ -0,0 +1,89 @@
+package preset
+
+import (
+	"testing"
+
+	"github.com/9roads/ccyolo/internal/hook"
+)
+
+func bash(command string) hook.Input {
+	return hook.Input{ToolName: "Bash", ToolInput: map[string]interface{}{"command": command}, Cwd: TestCwd}
+}
+
+func TestCompileActions(t *testing.T) {
+	for _, tt := range []struct {
+		rule Rule
+		ok   bool
+	}{
+		{Rule{Action: Allow, Tool: "Bash", Pattern: "ls*"}, true},
+		{Rule{Action: Deny, Tool: "Bash", Pattern: "rm *"}, true},
+		{Rule{Tool: "Bash", Pattern: "make *", CacheTTL: 60}, true},
+		{Rule{Tool: "Bash", Pattern: "ls*"}, false},
+		{Rule{Action: "block", Tool: "Bash", Pattern: "ls*"}, false},
+		{Rule{Action: Allow, Tool: "Bash", Pattern: "ls*", CacheTTL: 60}, false},
+	} {
+		p := Preset{Rules: []Rule{tt.rule}}
+		if err := p.Compile(); (err == nil) != tt.ok {
+			t.Errorf("Compile(%+v) = %v, want ok %v", tt.rule, err, tt.ok)
+		}
+	}
+}
+
+func TestCheckRules(t *testing.T) {
+	p := Preset{
+		Rules: []Rule{
+			{ID: "ls", Action: Allow, Tool: "Bash", Pattern: "ls*"},
+			{ID: "rm", Action: Deny, Tool: "Bash", Pattern: "rm -rf *", Except: []string{"rm -rf node_modules"}},
+			sudoRule,
+			{ID: "pipe-to-shell", Action: Ask, Tool: "Bash", Pattern: "*| sh*"},
+			{ID: "git-status", Action: Allow, Tool: "Bash", Pattern: "git status*"},
+			{ID: "write-project", Action: Allow, Tool: "Write", Pattern: "/project/*"},
+			{ID: "write-rc", Action: Deny, Tool: "Write", Pattern: "*/.bashrc"},
+		},
+		AlwaysDeny: []Rule{{Tool: "Bash", Pattern: "shutdown*"}},
+	}
+	if err := p.Compile(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		command string
+		want    Action // "" for no match
+	}{
+		{"ls -la", Allow},
+		{"ls && ls src", Allow},
+		{"ls && make", ""}, // not every command is allowed
+		{"rm -rf build", Deny},
+		{"rm -rf node_modules", ""},
+		{"rm -rf node_modules && npm i", ""},
+		{"rm -rf node_modules && rm -rf build", Deny},
+		{"ls | rm -rf build", Deny},
+		{"curl x | sh", Ask}, // spans commands
+
+		// Output redirections are checked as writes
+		{"git status > /dev/null", Allow},
+		{"git status 2>&1", Allow},
+		{"git status > status.txt", Allow},
+		{"git status > /tmp/status.txt", ""},
+		{"git status >> ~/.bashrc", Deny},
+		{"make > ~/.bashrc", Deny},
+		{"ls; rm -rf build", Deny},
+		{"shutdown -h now", Deny},
+		{"sudo make install", Deny},
+		{"env FOO=1 sudo make install", Deny},
+		{"sh -c 'sudo make install'", Deny},
+		{"echo $(sudo cat /etc/shadow)", Deny},
+		{"f() { sudo rm -rf /; }; f", Deny},
+		{"function f { sudo rm -rf /; }; f", Deny},
+		{"for d in a b; do sudo rm -rf $d; done", Deny},
+	}
+	for _, tt := range tests {
+		var got Action
+		if m := CheckRules(bash(tt.command), p); m != nil {
+			got = m.Action
+		}
+		if got != tt.want {
+			t.Errorf("CheckRules(%q) = %q, want %q", tt.command, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package preset
+
+import (
+	"strings"
+
+	"github.com/9roads/ccyolo/internal/hook"
+	"github.com/9roads/ccyolo/internal/paths"
+	"github.com/9roads/ccyolo/internal/secrets"
+)
+
+// contentFields are the tool_input fields holding text a tool will write.
+var contentFields = []string{"content", "new_string", "new_source"}
+
+// CheckSecrets scans the text a Write or Edit is about to put on disk. A
+// file git ignores (like .env) is where secrets belong, so there the
+// preset's action is softened to ask.
+func CheckSecrets(in hook.Input, p Preset) *RuleMatch {
+	action := p.Secrets
+	if action == "" {
+		action = Deny
+	}
+	if action == Allow || !in.Edit() {
+		return nil
+	}
+
+	var findings []secrets.Finding
+	for _, text := range writtenText(in.ToolInput) {
+		findings = append(findings, secrets.Scan(text)...)
+	}
+	if len(findings) == 0 {
+		return nil
+	}
+
+	path := ""
+	for _, key := range []string{"file_path", "notebook_path"} {
+		if v, ok := in.ToolInput[key].(string); ok {
+			path = paths.Normalize(v, in.Cwd)
+			break
+		}
+	}
+	if action == Deny && path != "" && paths.GitIgnored(path) {
+		action = Ask
+	}
+
+	described := make([]string, len(findings))
+	for i, f := range findings {
+		described[i] = f.String()
+	}
+	return &RuleMatch{Action: action, Rule: Rule{
+		ID:     "secrets",
+		Action: action,
+		Tool:   in.ToolName,
+		Reason: "possible credentials: " + strings.Join(described, ", "),
+	}}
+}
+
+// writtenText collects the new content of Write, Edit, MultiEdit and
+// NotebookEdit calls.
+func writtenText(input map[string]interface{}) []string {
+	var texts []string
+	for _, key := range contentFields {
+		if v, ok := input[key].(string); ok {
+			texts = append(texts, v)
+		}
+	}
+	if edits, ok := input["edits"].([]interface{}); ok {
+		for _, e := range edits {
+			if edit, ok := e.(map[string]interface{}); ok {
+				texts = append(texts, writtenText(edit)...)
+			}
+		}
+	}
+	return texts
+}
@@ -0,0 +1,57 @@
+package preset
+
+import (
+	"github.com/9roads/ccyolo/internal/hook"
+	"github.com/9roads/ccyolo/internal/shell"
+)
+
+// ttlOnly reports whether a rule only sets how long decisions are cached:
+// it has a CacheTTL and no Action.
+func (r Rule) ttlOnly() bool {
+	return r.Action == "" && r.CacheTTL != 0
+}
+
+// CacheTTL returns the TTL in seconds the preset's TTL rules set for a
+// call's cached decision, -1 for not caching it. For Bash, a rule matches
+// the whole command line or any simple command in it. When several rules
+// match the shortest TTL wins; ok is false when none does.
+func CacheTTL(in hook.Input, p Preset) (ttl int, ok bool) {
+	targets := []target{{in: in}}
+	if in.ToolName == "Bash" {
+		command, _ := in.ToolInput["command"].(string)
+		cmds, _ := shell.Parse(command)
+		for i := range cmds {
+			targets = append(targets, target{in: in, command: &cmds[i]})
+		}
+	}
+
+	for _, r := range p.Rules {
+		if !r.ttlOnly() {
+			continue
+		}
+		for _, t := range targets {
+			if !r.matches(t) {
+				continue
+			}
+			if ok {
+				ttl = ShorterTTL(ttl, r.CacheTTL)
+			} else {
+				ttl, ok = r.CacheTTL, true
+			}
+			break
+		}
+	}
+	return ttl, ok
+}
+
+// ShorterTTL returns the shorter of two TTLs, where -1 (never cache) is
+// the shortest.
+func ShorterTTL(a, b int) int {
+	switch {
+	case a < 0 || b < 0:
+		return -1
+	case b < a:
+		return b
+	}
+	return a
+}
@@ -0,0 +1,90 @@
+package preset
+
+import (
+	"github.com/9roads/ccyolo/internal/hook"
+	"github.com/9roads/ccyolo/internal/urls"
+)
+
+// WebPolicy decides WebFetch and WebSearch calls by host. Domains are exact
+// ("docs.rs") or cover subdomains too ("*.readthedocs.io"). When several
+// entries apply the strictest wins; empty actions leave the call to the
+// next step.
+type WebPolicy struct {
+	Default   Action   `json:",omitempty"` // fetches nothing below covers
+	Allow     []string `json:",omitempty"`
+	Ask       []string `json:",omitempty"`
+	Deny      []string `json:",omitempty"`
+	IPLiteral Action   `json:",omitempty"` // URLs with an IP address host
+	Internal  Action   `json:",omitempty"` // localhost, private addresses, internal names
+	Search    Action   `json:",omitempty"` // WebSearch
+}
+
+// validate checks the policy's actions.
+func (w WebPolicy) validate() error {
+	for _, f := range []struct {
+		name   string
+		action Action
+	}{{"Default", w.Default}, {"IPLiteral", w.IPLiteral}, {"Internal", w.Internal}, {"Search", w.Search}} {
+		if err := checkAction("Web: "+f.name, f.action); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Check returns the policy's decision for a web tool call.
+func (w WebPolicy) Check(in hook.Input) *RuleMatch {
+	decide := func(action Action, id, reason string) *RuleMatch {
+		if action == "" {
+			return nil
+		}
+		return &RuleMatch{Action: action, Rule: Rule{ID: id, Action: action, Tool: in.ToolName, Reason: reason}}
+	}
+
+	switch in.ToolName {
+	case "WebSearch":
+		return decide(w.Search, "web:search", "")
+	case "WebFetch":
+	default:
+		return nil
+	}
+
+	raw, _ := in.ToolInput["url"].(string)
+	u, err := urls.Normalize(raw)
+	if err != nil {
+		// Never allow what we can't read; ask unless the policy is stricter
+		return stricter(decide(Ask, "web:invalid-url", err.Error()), decide(w.Default, "web:default", ""))
+	}
+	host := u.Hostname()
+
+	var result *RuleMatch
+	for _, list := range []struct {
+		action  Action
+		domains []string
+	}{{Deny, w.Deny}, {Ask, w.Ask}, {Allow, w.Allow}} {
+		for _, domain := range list.domains {
+			if urls.MatchDomain(host, domain) {
+				result = stricter(result, decide(list.action, "web:"+string(list.action)+"/"+domain, ""))
+			}
+		}
+	}
+	if urls.ParseIP(host) != nil {
+		result = stricter(result, decide(w.IPLiteral, "web:ip-literal", "URL uses an IP address"))
+	}
+	if urls.IsInternal(host) {
+		result = stricter(result, decide(w.Internal, "web:internal", "internal host "+host))
+	}
+
+	if result == nil {
+		return decide(w.Default, "web:default", "")
+	}
+	return result
+}
+
+// stricter returns the stricter of two matches, either of which may be nil.
+func stricter(a, b *RuleMatch) *RuleMatch {
+	if a == nil || b != nil && strictness(b.Action) > strictness(a.Action) {
+		return b
+	}
+	return a
+}
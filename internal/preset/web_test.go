@@ -0,0 +1,21 @@
+package preset
+
+import "testing"
+
+func TestCompileWebActions(t *testing.T) {
+	for _, tt := range []struct {
+		web WebPolicy
+		ok  bool
+	}{
+		{WebPolicy{Default: Ask, Internal: Deny, Search: Allow, Allow: []string{"docs.rs"}}, true},
+		{WebPolicy{Default: "block"}, false},
+		{WebPolicy{IPLiteral: "Deny"}, false},
+		{WebPolicy{Internal: "no"}, false},
+		{WebPolicy{Search: "allowed"}, false},
+	} {
+		p := Preset{Web: &tt.web}
+		if err := p.Compile(); (err == nil) != tt.ok {
+			t.Errorf("Compile(%+v) = %v, want ok %v", tt.web, err, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package secrets
+
+import (
+	"math"
+	"regexp"
+	"strings"
+)
+
+// Finding is a likely credential in scanned text. Value is already redacted.
+type Finding struct {
+	Kind  string
+	Value string
+}
+
+func (f Finding) String() string {
+	return f.Kind + " (" + f.Value + ")"
+}
+
+type detector struct {
+	kind string
+	re   *regexp.Regexp
+	// group is the submatch holding the secret itself (0: whole match)
+	group int
+	// minEntropy rejects low-entropy values such as placeholders (0: off)
+	minEntropy float64
+}
+
+var detectors = []detector{
+	{kind: "AWS access key", re: regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`)},
+	{kind: "AWS secret key", re: regexp.MustCompile(`(?i)aws_secret_access_key["']?\s*[:=]\s*["']?([A-Za-z0-9/+=]{40})\b`), group: 1, minEntropy: 3.5},
+	{kind: "Anthropic API key", re: regexp.MustCompile(`\bsk-ant-[A-Za-z0-9_-]{20,}`)},
+	{kind: "OpenAI API key", re: regexp.MustCompile(`\bsk-(?:proj-)?[A-Za-z0-9_-]{32,}`), minEntropy: 3.5},
+	{kind: "GitHub token", re: regexp.MustCompile(`\b(?:gh[pousr]_[A-Za-z0-9]{36}|github_pat_[A-Za-z0-9_]{60,})\b`)},
+	{kind: "Slack token", re: regexp.MustCompile(`\bxox[abposr]-[A-Za-z0-9-]{10,}`)},
+	{kind: "private key", re: regexp.MustCompile(`-----BEGIN (?:[A-Z]+ )*PRIVATE KEY(?: BLOCK)?-----`)},
+	{kind: "JWT", re: regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{10,}\.eyJ[A-Za-z0-9_-]{10,}\.[A-Za-z0-9_-]{10,}`)},
+	{kind: "npm token", re: regexp.MustCompile(`\bnpm_[A-Za-z0-9]{36}\b`)},
+	{kind: "npm auth token", re: regexp.MustCompile(`(?m)_auth(?:Token)?\s*=\s*["']?([^\s"'$]{16,})`), group: 1, minEntropy: 3},
+	{kind: "secret assignment", re: regexp.MustCompile(`(?i)\b[A-Z0-9_]*(?:secret|token|passwd|password|api_?key|apikey|access_?key)["']?\s*[:=]\s*["']?([A-Za-z0-9/+_=.-]{20,})`), group: 1, minEntropy: 4},
+}
+
+// Scan looks for credentials in text. Values that only look like secrets
+// by name are kept when their Shannon entropy is high enough to rule out
+// placeholders like "your-api-key-here".
+func Scan(text string) []Finding {
+	var findings []Finding
+	seen := make(map[string]bool)
+	for _, d := range detectors {
+		for _, m := range d.re.FindAllStringSubmatch(text, -1) {
+			value := m[d.group]
+			if d.minEntropy > 0 && entropy(value) < d.minEntropy {
+				continue
+			}
+			if seen[value] {
+				continue
+			}
+			seen[value] = true
+			findings = append(findings, Finding{Kind: d.kind, Value: Redact(value)})
+		}
+	}
+	return findings
+}
+
+// RedactText replaces every credential Scan would find in text with its
+// redacted form. Submatches are located in text itself: a pattern ending in
+// \b may not match again on its match alone.
+func RedactText(text string) string {
+	for _, d := range detectors {
+		var b strings.Builder
+		last := 0
+		for _, m := range d.re.FindAllStringSubmatchIndex(text, -1) {
+			start, end := m[2*d.group], m[2*d.group+1]
+			if start < 0 {
+				continue
+			}
+			value := text[start:end]
+			if d.minEntropy > 0 && entropy(value) < d.minEntropy {
+				continue
+			}
+			b.WriteString(text[last:start])
+			b.WriteString(Redact(value))
+			last = end
+		}
+		if last > 0 {
+			b.WriteString(text[last:])
+			text = b.String()
+		}
+	}
+	return text
+}
+
+// Redact keeps just enough of a secret to recognize it.
+func Redact(value string) string {
+	if strings.HasPrefix(value, "-----") {
+		return value
+	}
+	keep := 4
+	if i := strings.LastIndexAny(value[:min(len(value), 12)], "-_"); i >= 0 && i < 10 {
+		// Keep a prefix such as "sk-ant-" or "ghp_" intact
+		keep = i + 1
+	}
+	if keep > len(value)/3 {
+		keep = len(value) / 3
+	}
+	return value[:keep] + strings.Repeat("*", 8)
+}
+
+// entropy is the Shannon entropy of s in bits per character.
+func entropy(s string) float64 {
+	counts := make(map[rune]int)
+	for _, r := range s {
+		counts[r]++
+	}
+	var h float64
+	n := float64(len(s))
+	for _, c := range counts {
+		p := float64(c) / n
+		h -= p * math.Log2(p)
+	}
+	return h
+}
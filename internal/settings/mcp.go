@@ -0,0 +1,87 @@
+package settings
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/9roads/ccyolo/internal/paths"
+)
+
+// MCPServer is an entry of a Claude Code mcpServers block.
+type MCPServer struct {
+	Type        string   `json:"type,omitempty"`
+	Command     string   `json:"command,omitempty"`
+	Args        []string `json:"args,omitempty"`
+	URL         string   `json:"url,omitempty"`
+	Description string   `json:"description,omitempty"`
+}
+
+// Describe summarizes the server for the safety prompt.
+func (s MCPServer) Describe() string {
+	if s.Description != "" {
+		return s.Description
+	}
+	if s.URL != "" {
+		return s.Type + " server at " + s.URL
+	}
+	return "runs: " + strings.TrimSpace(s.Command+" "+strings.Join(s.Args, " "))
+}
+
+// MCPServers collects the mcpServers blocks Claude Code reads: the user's
+// settings.json, ~/.claude.json (global and the entry for this project) and
+// the .mcp.json at the project root. Later sources win.
+func MCPServers(cwd string) map[string]MCPServer {
+	servers := make(map[string]MCPServer)
+	home, _ := os.UserHomeDir()
+
+	var userSettings struct {
+		MCPServers map[string]MCPServer `json:"mcpServers"`
+	}
+	readJSON(ClaudeSettingsPath(), &userSettings)
+	mergeServers(servers, userSettings.MCPServers)
+
+	var claudeJSON struct {
+		MCPServers map[string]MCPServer `json:"mcpServers"`
+		Projects   map[string]struct {
+			MCPServers map[string]MCPServer `json:"mcpServers"`
+		} `json:"projects"`
+	}
+	readJSON(filepath.Join(home, ".claude.json"), &claudeJSON)
+	mergeServers(servers, claudeJSON.MCPServers)
+
+	if cwd != "" {
+		for dir := cwd; ; dir = filepath.Dir(dir) {
+			if project, ok := claudeJSON.Projects[dir]; ok {
+				mergeServers(servers, project.MCPServers)
+				break
+			}
+			if filepath.Dir(dir) == dir {
+				break
+			}
+		}
+
+		var mcpJSON struct {
+			MCPServers map[string]MCPServer `json:"mcpServers"`
+		}
+		readJSON(filepath.Join(paths.ProjectRoot(cwd), ".mcp.json"), &mcpJSON)
+		mergeServers(servers, mcpJSON.MCPServers)
+	}
+
+	return servers
+}
+
+func mergeServers(dst, src map[string]MCPServer) {
+	for name, s := range src {
+		dst[name] = s
+	}
+}
+
+func readJSON(path string, v interface{}) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return
+	}
+	json.Unmarshal(data, v)
+}
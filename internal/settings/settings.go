@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+
+	"github.com/9roads/ccyolo/internal/hook"
 )
 
 type Hook struct {
@@ -18,9 +20,13 @@ type HookMatcher struct {
 }
 
 type HooksConfig struct {
+	PreToolUse        []HookMatcher `json:"PreToolUse,omitempty"`
 	PermissionRequest []HookMatcher `json:"PermissionRequest,omitempty"`
 }
 
+// hookEvents are the events ccyolo can be registered under.
+var hookEvents = []string{hook.EventPreToolUse, hook.EventPermissionRequest}
+
 type Settings struct {
 	Hooks   HooksConfig            `json:"hooks,omitempty"`
 	Unknown map[string]interface{} `json:"-"` // preserve other fields
@@ -62,7 +68,9 @@ func saveRaw(raw map[string]interface{}) error {
 	return os.WriteFile(ClaudeSettingsPath(), data, 0644)
 }
 
-func AddHook(command string) error {
+// AddHook registers command under the given hook event, moving an existing
+// ccyolo hook from the other event if there is one.
+func AddHook(command, event string) error {
 	raw, err := loadRaw()
 	if err != nil {
 		return fmt.Errorf("failed to load settings: %w", err)
@@ -75,25 +83,13 @@ func AddHook(command string) error {
 		raw["hooks"] = hooks
 	}
 
-	// Check if ccyolo hook already exists in PreToolUse
-	permReq, _ := hooks["PreToolUse"].([]interface{})
-	for _, item := range permReq {
-		if matcher, ok := item.(map[string]interface{}); ok {
-			if hooksList, ok := matcher["hooks"].([]interface{}); ok {
-				for _, h := range hooksList {
-					if hook, ok := h.(map[string]interface{}); ok {
-						if cmd, ok := hook["command"].(string); ok {
-							if cmd == command || containsCCYolo(cmd) {
-								return fmt.Errorf("ccyolo hook already installed")
-							}
-						}
-					}
-				}
-			}
-		}
+	if installedEvent(hooks) == event {
+		return fmt.Errorf("ccyolo hook already installed")
 	}
+	removeFromHooks(hooks)
 
-	// Add new hook to PreToolUse inside "hooks" wrapper
+	// Add new hook to the event inside "hooks" wrapper
+	matchers, _ := hooks[event].([]interface{})
 	newHook := map[string]interface{}{
 		"matcher": "*",
 		"hooks": []interface{}{
@@ -104,8 +100,7 @@ func AddHook(command string) error {
 		},
 	}
 
-	permReq = append(permReq, newHook)
-	hooks["PreToolUse"] = permReq
+	hooks[event] = append(matchers, newHook)
 
 	return saveRaw(raw)
 }
@@ -121,41 +116,65 @@ func RemoveHook() error {
 		return nil // No hooks section
 	}
 
-	permReq, ok := hooks["PreToolUse"].([]interface{})
-	if !ok {
-		return nil // No PreToolUse hooks
-	}
-
-	// Filter out ccyolo hooks
-	var filtered []interface{}
-	for _, item := range permReq {
-		keep := true
-		if matcher, ok := item.(map[string]interface{}); ok {
-			if hooksList, ok := matcher["hooks"].([]interface{}); ok {
-				for _, h := range hooksList {
-					if hook, ok := h.(map[string]interface{}); ok {
-						if cmd, ok := hook["command"].(string); ok {
-							if containsCCYolo(cmd) {
-								keep = false
-								break
-							}
-						}
-					}
-				}
+	removeFromHooks(hooks)
+	return saveRaw(raw)
+}
+
+// removeFromHooks filters ccyolo hooks out of every event it may be under
+func removeFromHooks(hooks map[string]interface{}) {
+	for _, event := range hookEvents {
+		matchers, ok := hooks[event].([]interface{})
+		if !ok {
+			continue
+		}
+
+		var filtered []interface{}
+		for _, item := range matchers {
+			if !isCCYoloMatcher(item) {
+				filtered = append(filtered, item)
 			}
 		}
-		if keep {
-			filtered = append(filtered, item)
+
+		if len(filtered) == 0 {
+			delete(hooks, event)
+		} else {
+			hooks[event] = filtered
 		}
 	}
+}
 
-	if len(filtered) == 0 {
-		delete(hooks, "PreToolUse")
-	} else {
-		hooks["PreToolUse"] = filtered
+func isCCYoloMatcher(item interface{}) bool {
+	matcher, ok := item.(map[string]interface{})
+	if !ok {
+		return false
 	}
+	hooksList, ok := matcher["hooks"].([]interface{})
+	if !ok {
+		return false
+	}
+	for _, h := range hooksList {
+		if hook, ok := h.(map[string]interface{}); ok {
+			if cmd, ok := hook["command"].(string); ok {
+				if containsCCYolo(cmd) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
 
-	return saveRaw(raw)
+// installedEvent returns the event ccyolo is registered under, or "".
+func installedEvent(hooks map[string]interface{}) string {
+	for _, event := range hookEvents {
+		matchers, _ := hooks[event].([]interface{})
+		for _, item := range matchers {
+			if isCCYoloMatcher(item) {
+				return event
+			}
+		}
+	}
+	return ""
 }
 
 func containsCCYolo(s string) bool {
@@ -175,36 +194,20 @@ func contains(s, substr string) bool {
 
 // IsHookInstalled checks if ccyolo hook is registered with Claude Code
 func IsHookInstalled() bool {
+	return InstalledEvent() != ""
+}
+
+// InstalledEvent returns the hook event ccyolo is registered under, or "".
+func InstalledEvent() string {
 	raw, err := loadRaw()
 	if err != nil {
-		return false
+		return ""
 	}
 
 	hooks, ok := raw["hooks"].(map[string]interface{})
 	if !ok {
-		return false
+		return ""
 	}
 
-	permReq, ok := hooks["PreToolUse"].([]interface{})
-	if !ok {
-		return false
-	}
-
-	for _, item := range permReq {
-		if matcher, ok := item.(map[string]interface{}); ok {
-			if hooksList, ok := matcher["hooks"].([]interface{}); ok {
-				for _, h := range hooksList {
-					if hook, ok := h.(map[string]interface{}); ok {
-						if cmd, ok := hook["command"].(string); ok {
-							if containsCCYolo(cmd) {
-								return true
-							}
-						}
-					}
-				}
-			}
-		}
-	}
-
-	return false
+	return installedEvent(hooks)
 }
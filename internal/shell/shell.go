@@ -0,0 +1,656 @@
+package shell
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// Command is one simple command from a shell command line. Variable
+// assignments, reserved words and wrapper programs (env, nohup, xargs, ...)
+// are stripped so Args starts with the program that actually runs.
+type Command struct {
+	Args      []string
+	Redirects []Redirect
+}
+
+type Redirect struct {
+	Op     string // e.g. ">", ">>", "2>&", "<<"
+	Target string
+}
+
+// WrittenFiles returns the files the command's output redirections write,
+// other than /dev/null. Duplicated descriptors such as 2>&1 aren't files.
+func (c Command) WrittenFiles() []string {
+	var files []string
+	for _, r := range c.Redirects {
+		if !strings.Contains(r.Op, ">") || r.Target == "/dev/null" {
+			continue
+		}
+		if strings.HasSuffix(r.Op, ">&") && (r.Target == "-" || strings.Trim(r.Target, "0123456789") == "") {
+			continue
+		}
+		files = append(files, r.Target)
+	}
+	return files
+}
+
+func (c Command) String() string {
+	parts := append([]string{}, c.Args...)
+	for _, r := range c.Redirects {
+		if strings.HasSuffix(r.Op, "&") {
+			parts = append(parts, r.Op+r.Target)
+		} else {
+			parts = append(parts, r.Op+" "+r.Target)
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// Parse splits a shell command line into every simple command it would run:
+// each side of pipes and &&, || and ; lists, the insides of subshells, command
+// and process substitutions, heredoc expansions and `sh -c` / eval strings.
+// Quotes are removed from words; expansions like $HOME are kept literally.
+func Parse(src string) ([]Command, error) {
+	p := &parser{src: src}
+	if err := p.parseList(false); err != nil {
+		return nil, err
+	}
+	return p.cmds, nil
+}
+
+type parser struct {
+	src      string
+	pos      int
+	cmds     []Command
+	heredocs []heredoc
+}
+
+type heredoc struct {
+	delim  string
+	strip  bool // <<- strips leading tabs
+	expand bool // unquoted delimiter: the body undergoes expansion
+}
+
+func (p *parser) peek(n int) byte {
+	if p.pos+n < len(p.src) {
+		return p.src[p.pos+n]
+	}
+	return 0
+}
+
+// parseList reads commands until the end of input, or until the ")" closing
+// a command substitution when inSubst is set.
+func (p *parser) parseList(inSubst bool) error {
+	var words []string
+	var redirs []Redirect
+	depth := 0
+
+	flush := func() error {
+		err := p.addCommand(words, redirs)
+		words, redirs = nil, nil
+		return err
+	}
+
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		switch {
+		case c == ' ' || c == '\t' || c == '\r':
+			p.pos++
+		case c == '\\' && p.peek(1) == '\n':
+			p.pos += 2
+		case c == '\n':
+			p.pos++
+			if err := flush(); err != nil {
+				return err
+			}
+			if err := p.readHeredocs(); err != nil {
+				return err
+			}
+		case c == '#':
+			for p.pos < len(p.src) && p.src[p.pos] != '\n' {
+				p.pos++
+			}
+		case c == '&' && p.peek(1) == '>':
+			r, err := p.readRedirect("")
+			if err != nil {
+				return err
+			}
+			redirs = append(redirs, r)
+		case c == ';' || c == '&' || c == '|':
+			if err := flush(); err != nil {
+				return err
+			}
+			p.pos++
+			for p.pos < len(p.src) && strings.IndexByte(";&|", p.src[p.pos]) >= 0 {
+				p.pos++
+			}
+		case c == '(':
+			if err := flush(); err != nil {
+				return err
+			}
+			depth++
+			p.pos++
+		case c == ')':
+			if err := flush(); err != nil {
+				return err
+			}
+			p.pos++
+			if depth == 0 {
+				if inSubst {
+					return nil
+				}
+				continue
+			}
+			depth--
+		case (c == '<' || c == '>') && p.peek(1) != '(':
+			r, err := p.readRedirect("")
+			if err != nil {
+				return err
+			}
+			redirs = append(redirs, r)
+		default:
+			w, err := p.readWord()
+			if err != nil {
+				return err
+			}
+			if isDigits(w) && p.pos < len(p.src) && (p.src[p.pos] == '<' || p.src[p.pos] == '>') && p.peek(1) != '(' {
+				r, err := p.readRedirect(w)
+				if err != nil {
+					return err
+				}
+				redirs = append(redirs, r)
+				continue
+			}
+			words = append(words, w)
+		}
+	}
+
+	if err := flush(); err != nil {
+		return err
+	}
+	if inSubst {
+		return fmt.Errorf("unterminated command substitution")
+	}
+	return nil
+}
+
+func (p *parser) readWord() (string, error) {
+	var b strings.Builder
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		switch c {
+		case ' ', '\t', '\r', '\n', ';', '&', '|', '(', ')':
+			return b.String(), nil
+		case '<', '>':
+			if p.peek(1) != '(' {
+				return b.String(), nil
+			}
+			// Process substitution: <(cmd) or >(cmd)
+			start := p.pos
+			p.pos += 2
+			if err := p.parseList(true); err != nil {
+				return "", err
+			}
+			b.WriteString(p.src[start:p.pos])
+		case '\\':
+			if p.pos+1 < len(p.src) && p.src[p.pos+1] != '\n' {
+				b.WriteByte(p.src[p.pos+1])
+			}
+			p.pos += 2
+		case '\'':
+			end := strings.IndexByte(p.src[p.pos+1:], '\'')
+			if end < 0 {
+				return "", fmt.Errorf("unterminated single quote")
+			}
+			b.WriteString(p.src[p.pos+1 : p.pos+1+end])
+			p.pos += end + 2
+		case '"':
+			p.pos++
+			if err := p.readDoubleQuoted(&b); err != nil {
+				return "", err
+			}
+		case '$':
+			if err := p.readDollar(&b); err != nil {
+				return "", err
+			}
+		case '`':
+			if err := p.readBackticks(&b); err != nil {
+				return "", err
+			}
+		default:
+			b.WriteByte(c)
+			p.pos++
+		}
+	}
+	return b.String(), nil
+}
+
+func (p *parser) readDoubleQuoted(b *strings.Builder) error {
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		switch c {
+		case '"':
+			p.pos++
+			return nil
+		case '\\':
+			next := p.peek(1)
+			if next != 0 && strings.IndexByte("$`\"\\\n", next) >= 0 {
+				if next != '\n' {
+					b.WriteByte(next)
+				}
+				p.pos += 2
+			} else {
+				b.WriteByte(c)
+				p.pos++
+			}
+		case '$':
+			if err := p.readDollar(b); err != nil {
+				return err
+			}
+		case '`':
+			if err := p.readBackticks(b); err != nil {
+				return err
+			}
+		default:
+			b.WriteByte(c)
+			p.pos++
+		}
+	}
+	return fmt.Errorf("unterminated double quote")
+}
+
+// readDollar handles $(...), $((...)), ${...} and $'...'. Command
+// substitutions are parsed as commands; the word keeps their source text.
+func (p *parser) readDollar(b *strings.Builder) error {
+	start := p.pos
+	switch p.peek(1) {
+	case '(':
+		if p.peek(2) == '(' {
+			p.pos += 3
+			depth := 2
+			for depth > 0 {
+				if p.pos >= len(p.src) {
+					return fmt.Errorf("unterminated arithmetic expansion")
+				}
+				switch p.src[p.pos] {
+				case '(':
+					depth++
+				case ')':
+					depth--
+				}
+				p.pos++
+			}
+		} else {
+			p.pos += 2
+			if err := p.parseList(true); err != nil {
+				return err
+			}
+		}
+		b.WriteString(p.src[start:p.pos])
+	case '{':
+		p.pos += 2
+		var discard strings.Builder
+		for {
+			if p.pos >= len(p.src) {
+				return fmt.Errorf("unterminated parameter expansion")
+			}
+			c := p.src[p.pos]
+			if c == '}' {
+				p.pos++
+				break
+			}
+			var err error
+			switch c {
+			case '$':
+				err = p.readDollar(&discard)
+			case '`':
+				err = p.readBackticks(&discard)
+			case '"':
+				p.pos++
+				err = p.readDoubleQuoted(&discard)
+			case '\\':
+				p.pos += 2
+			default:
+				p.pos++
+			}
+			if err != nil {
+				return err
+			}
+		}
+		b.WriteString(p.src[start:p.pos])
+	case '\'':
+		// ANSI-C quoting
+		p.pos += 2
+		for p.pos < len(p.src) {
+			c := p.src[p.pos]
+			switch c {
+			case '\'':
+				p.pos++
+				return nil
+			case '\\':
+				switch p.peek(1) {
+				case 'n':
+					b.WriteByte('\n')
+				case 't':
+					b.WriteByte('\t')
+				default:
+					b.WriteByte(p.peek(1))
+				}
+				p.pos += 2
+			default:
+				b.WriteByte(c)
+				p.pos++
+			}
+		}
+		return fmt.Errorf("unterminated $' quote")
+	default:
+		b.WriteByte('$')
+		p.pos++
+	}
+	return nil
+}
+
+func (p *parser) readBackticks(b *strings.Builder) error {
+	start := p.pos
+	p.pos++
+	var inner strings.Builder
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		if c == '\\' && strings.IndexByte("`\\$", p.peek(1)) >= 0 && p.peek(1) != 0 {
+			inner.WriteByte(p.src[p.pos+1])
+			p.pos += 2
+			continue
+		}
+		if c == '`' {
+			p.pos++
+			sub, err := Parse(inner.String())
+			if err != nil {
+				return err
+			}
+			p.cmds = append(p.cmds, sub...)
+			b.WriteString(p.src[start:p.pos])
+			return nil
+		}
+		inner.WriteByte(c)
+		p.pos++
+	}
+	return fmt.Errorf("unterminated backquote")
+}
+
+func (p *parser) readRedirect(fd string) (Redirect, error) {
+	start := p.pos
+	if p.src[p.pos] == '&' {
+		p.pos++
+	}
+	c := p.src[p.pos]
+	p.pos++
+	switch c {
+	case '>':
+		if n := p.peek(0); n == '>' || n == '|' || n == '&' {
+			p.pos++
+		}
+	case '<':
+		switch p.peek(0) {
+		case '<':
+			p.pos++
+			if n := p.peek(0); n == '<' || n == '-' {
+				p.pos++
+			}
+		case '>', '&':
+			p.pos++
+		}
+	}
+	op := fd + p.src[start:p.pos]
+
+	for p.pos < len(p.src) && (p.src[p.pos] == ' ' || p.src[p.pos] == '\t') {
+		p.pos++
+	}
+	rawStart := p.pos
+	target, err := p.readWord()
+	if err != nil {
+		return Redirect{}, err
+	}
+
+	if op == "<<" || op == "<<-" {
+		raw := p.src[rawStart:p.pos]
+		p.heredocs = append(p.heredocs, heredoc{
+			delim:  target,
+			strip:  op == "<<-",
+			expand: !strings.ContainsAny(raw, `'"\`),
+		})
+	}
+	return Redirect{Op: op, Target: target}, nil
+}
+
+// readHeredocs consumes the bodies of heredocs started on the previous line.
+// Unquoted bodies are scanned for command substitutions.
+func (p *parser) readHeredocs() error {
+	pending := p.heredocs
+	p.heredocs = nil
+	for _, h := range pending {
+		var body strings.Builder
+		for p.pos < len(p.src) {
+			end := strings.IndexByte(p.src[p.pos:], '\n')
+			line := p.src[p.pos:]
+			if end >= 0 {
+				line = p.src[p.pos : p.pos+end]
+				p.pos += end + 1
+			} else {
+				p.pos = len(p.src)
+			}
+			check := line
+			if h.strip {
+				check = strings.TrimLeft(line, "\t")
+			}
+			if check == h.delim {
+				break
+			}
+			body.WriteString(line)
+			body.WriteByte('\n')
+		}
+		if !h.expand {
+			continue
+		}
+
+		q := &parser{src: body.String()}
+		var discard strings.Builder
+		for q.pos < len(q.src) {
+			var err error
+			switch q.src[q.pos] {
+			case '\\':
+				q.pos += 2
+			case '$':
+				err = q.readDollar(&discard)
+			case '`':
+				err = q.readBackticks(&discard)
+			default:
+				q.pos++
+			}
+			if err != nil {
+				return err
+			}
+		}
+		p.cmds = append(p.cmds, q.cmds...)
+	}
+	return nil
+}
+
+func (p *parser) addCommand(words []string, redirs []Redirect) error {
+	args := stripPrefix(words)
+	args = unwrap(args)
+	if len(args) == 0 && len(redirs) == 0 {
+		return nil
+	}
+	p.cmds = append(p.cmds, Command{Args: args, Redirects: redirs})
+
+	// Strings run by `sh -c` and eval are commands too.
+	if script, ok := inlineScript(args); ok {
+		sub, err := Parse(script)
+		if err != nil {
+			return err
+		}
+		p.cmds = append(p.cmds, sub...)
+	}
+	return nil
+}
+
+var reservedWords = map[string]bool{
+	"!": true, "{": true, "}": true, "if": true, "then": true, "else": true,
+	"elif": true, "fi": true, "while": true, "until": true, "do": true,
+	"done": true, "esac": true,
+}
+
+// stripPrefix drops leading reserved words, function names, loop variables
+// and variable assignments. A function body after "{" and a loop body after
+// "do" are still commands.
+func stripPrefix(words []string) []string {
+	for len(words) > 0 {
+		w := words[0]
+		switch {
+		case reservedWords[w]:
+			words = words[1:]
+		case w == "function":
+			words = words[min(2, len(words)):]
+		case w == "for" || w == "select":
+			words = words[min(2, len(words)):]
+			if len(words) > 0 && words[0] == "in" {
+				// The rest of the segment is the word list
+				return nil
+			}
+		case w == "case":
+			// The rest of the segment is the subject and a pattern
+			return nil
+		case isAssignment(w):
+			words = words[1:]
+		default:
+			return words
+		}
+	}
+	return words
+}
+
+type wrapper struct {
+	valueFlags map[string]bool // flags that consume the following word
+	positional int             // arguments between the flags and the wrapped command
+}
+
+func flags(names ...string) map[string]bool {
+	m := make(map[string]bool, len(names))
+	for _, n := range names {
+		m[n] = true
+	}
+	return m
+}
+
+var wrappers = map[string]wrapper{
+	"env":     {valueFlags: flags("-u", "--unset", "-C", "--chdir")},
+	"nohup":   {},
+	"exec":    {valueFlags: flags("-a")},
+	"builtin": {},
+	"command": {},
+	"time":    {},
+	"nice":    {valueFlags: flags("-n", "--adjustment")},
+	"timeout": {valueFlags: flags("-s", "--signal", "-k", "--kill-after"), positional: 1},
+	"stdbuf":  {valueFlags: flags("-i", "-o", "-e")},
+	"xargs": {valueFlags: flags("-I", "-n", "-P", "-d", "-L", "-s", "-E", "-a",
+		"--max-args", "--max-procs", "--delimiter", "--arg-file", "--max-lines", "--max-chars", "--eof", "--replace")},
+}
+
+// unwrap strips wrapper programs that run another command, so that
+// `nohup env FOO=1 xargs rm -rf` is evaluated as `rm -rf`.
+func unwrap(args []string) []string {
+	for len(args) > 0 {
+		name := path.Base(args[0])
+		w, ok := wrappers[name]
+		if !ok {
+			return args
+		}
+		// command -v/-V only looks a name up
+		if name == "command" && len(args) > 1 && (args[1] == "-v" || args[1] == "-V") {
+			return args
+		}
+
+		rest := args[1:]
+		for len(rest) > 0 {
+			a := rest[0]
+			if a == "--" {
+				rest = rest[1:]
+				break
+			}
+			if name == "env" && isAssignment(a) {
+				rest = rest[1:]
+				continue
+			}
+			if len(a) < 2 || a[0] != '-' {
+				break
+			}
+			if w.valueFlags[a] && len(rest) > 1 {
+				rest = rest[2:]
+			} else {
+				rest = rest[1:]
+			}
+		}
+		if len(rest) < w.positional {
+			return args
+		}
+		rest = rest[w.positional:]
+		if len(rest) == 0 {
+			return args
+		}
+		args = rest
+	}
+	return args
+}
+
+var shells = map[string]bool{"sh": true, "bash": true, "zsh": true, "dash": true, "ksh": true}
+
+// inlineScript returns the script passed to `sh -c` or eval.
+func inlineScript(args []string) (string, bool) {
+	if len(args) < 2 {
+		return "", false
+	}
+	name := path.Base(args[0])
+	if name == "eval" {
+		return strings.Join(args[1:], " "), true
+	}
+	if !shells[name] {
+		return "", false
+	}
+	for i, a := range args[1 : len(args)-1] {
+		if len(a) > 1 && a[0] == '-' && a[1] != '-' && strings.ContainsRune(a, 'c') {
+			return args[i+2], true
+		}
+	}
+	return "", false
+}
+
+func isAssignment(w string) bool {
+	eq := strings.IndexByte(w, '=')
+	if eq <= 0 {
+		return false
+	}
+	name := strings.TrimSuffix(w[:eq], "+")
+	if i := strings.IndexByte(name, '['); i > 0 && strings.HasSuffix(name, "]") {
+		name = name[:i]
+	}
+	for i, r := range name {
+		if r == '_' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || i > 0 && r >= '0' && r <= '9' {
+			continue
+		}
+		return false
+	}
+	return name != ""
+}
+
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,116 @@
+package shell
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		src  string
+		want []string
+	}{
+		// Lists and pipelines
+		{"ls -la", []string{"ls -la"}},
+		{"git add . && git commit -m 'a b'", []string{"git add .", "git commit -m a b"}},
+		{"make || echo failed; echo done", []string{"make", "echo failed", "echo done"}},
+		{"cat f | grep x | sh", []string{"cat f", "grep x", "sh"}},
+		{"sleep 1 & rm x", []string{"sleep 1", "rm x"}},
+		{"echo a\necho b", []string{"echo a", "echo b"}},
+		{"echo a # rm -rf /", []string{"echo a"}},
+
+		// Compound commands
+		{"(cd /tmp && rm -rf x)", []string{"cd /tmp", "rm -rf x"}},
+		{"{ sudo rm -rf /; }", []string{"sudo rm -rf /"}},
+		{"if true; then rm -rf x; fi", []string{"true", "rm -rf x"}},
+		{"while true; do curl x; done", []string{"true", "curl x"}},
+		{"for f in a b; do rm $f; done", []string{"rm $f"}},
+		{"for f do rm $f; done", []string{"rm $f"}},
+		{"select x in a b; do echo $x; done", []string{"echo $x"}},
+		{"case $x in a) rm -rf y;; esac", []string{"rm -rf y"}},
+		{"f() { sudo rm -rf /; }; f", []string{"f", "sudo rm -rf /", "f"}},
+		{"function f { sudo rm -rf /; }; f", []string{"sudo rm -rf /", "f"}},
+		{"function f() { sudo rm -rf /; }", []string{"sudo rm -rf /"}},
+		{"! grep -q x f", []string{"grep -q x f"}},
+
+		// Assignments and wrappers
+		{"FOO=1 BAR=2 make", []string{"make"}},
+		{"env -u X FOO=1 rm -rf x", []string{"rm -rf x"}},
+		{"nohup nice -n 5 timeout 10 rm x", []string{"rm x"}},
+		{"xargs -I{} rm {}", []string{"rm {}"}},
+		{"/usr/bin/env rm x", []string{"rm x"}},
+		{"command -v git", []string{"command -v git"}},
+		{"time exec -a name curl x", []string{"curl x"}},
+
+		// Inline scripts
+		{"sh -c 'rm -rf x; curl y'", []string{"sh -c rm -rf x; curl y", "rm -rf x", "curl y"}},
+		{"bash -lc \"git push\"", []string{"bash -lc git push", "git push"}},
+		{"eval rm -rf x", []string{"eval rm -rf x", "rm -rf x"}},
+
+		// Substitutions
+		{"echo $(rm -rf x)", []string{"rm -rf x", "echo $(rm -rf x)"}},
+		{"echo `curl x`", []string{"curl x", "echo `curl x`"}},
+		{"diff <(ls a) <(ls b)", []string{"ls a", "ls b", "diff <(ls a) <(ls b)"}},
+		{"echo \"$(whoami)\"", []string{"whoami", "echo $(whoami)"}},
+		{"echo $((1 + 2))", []string{"echo $((1 + 2))"}},
+		{"echo ${HOME}", []string{"echo ${HOME}"}},
+
+		// Redirections
+		{"echo x > f", []string{"echo x > f"}},
+		{"cmd 2>&1 >> log", []string{"cmd 2>&1 >> log"}},
+	}
+	for _, tt := range tests {
+		cmds, err := Parse(tt.src)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.src, err)
+			continue
+		}
+		got := make([]string, len(cmds))
+		for i, c := range cmds {
+			got[i] = c.String()
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Parse(%q) = %q, want %q", tt.src, got, tt.want)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, src := range []string{
+		"echo 'x",
+		"echo \"x",
+		"echo $(ls",
+		"echo `ls",
+		"sh -c 'echo \"x'",
+	} {
+		if _, err := Parse(src); err == nil {
+			t.Errorf("Parse(%q): want error", src)
+		}
+	}
+}
+
+func TestWrittenFiles(t *testing.T) {
+	tests := []struct {
+		src  string
+		want []string
+	}{
+		{"ls", nil},
+		{"ls > out", []string{"out"}},
+		{"ls >> out 2> err", []string{"out", "err"}},
+		{"ls &> out", []string{"out"}},
+		{"ls >| out", []string{"out"}},
+		{"ls 2>&1 > /dev/null", nil},
+		{"ls >&2", nil},
+		{"ls >& out", []string{"out"}},
+		{"cat < in", nil},
+	}
+	for _, tt := range tests {
+		cmds, err := Parse(tt.src)
+		if err != nil || len(cmds) != 1 {
+			t.Fatalf("Parse(%q) = %v, %v", tt.src, cmds, err)
+		}
+		if got := cmds[0].WrittenFiles(); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("WrittenFiles(%q) = %q, want %q", tt.src, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package tools
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/9roads/ccyolo/internal/urls"
+)
+
+// Tool describes a Claude Code tool: what kind of operation it is and where
+// in tool_input its paths, command and URLs live.
+type Tool struct {
+	Name     string
+	ReadOnly bool // only reads; nothing else is approved in plan mode
+	Edit     bool // edits files; accepted by Claude Code itself in acceptEdits mode
+
+	PathFields []string // tool_input fields holding paths; the first present one is used
+	CwdDefault bool     // an empty path means the working directory
+	Command    string   // tool_input field holding a shell command
+	URL        string   // tool_input field holding a URL
+
+	// Summary describes a call in hook reasons; nil uses the main field
+	Summary func(input map[string]interface{}) string
+}
+
+var registry = map[string]Tool{}
+
+func register(t Tool) {
+	registry[t.Name] = t
+}
+
+func init() {
+	register(Tool{Name: "Bash", Command: "command", Summary: func(in map[string]interface{}) string {
+		return "Bash: " + truncate(str(in, "command"), 60)
+	}})
+	register(Tool{Name: "BashOutput", ReadOnly: true, Summary: func(in map[string]interface{}) string {
+		return "BashOutput: " + str(in, "bash_id")
+	}})
+	register(Tool{Name: "KillShell", Summary: func(in map[string]interface{}) string {
+		return "KillShell: " + str(in, "shell_id")
+	}})
+	register(Tool{Name: "KillBash", Summary: func(in map[string]interface{}) string {
+		return "KillBash: " + str(in, "shell_id")
+	}})
+
+	register(Tool{Name: "Read", ReadOnly: true, PathFields: []string{"file_path", "path"}})
+	register(Tool{Name: "NotebookRead", ReadOnly: true, PathFields: []string{"notebook_path"}})
+	register(Tool{Name: "Glob", ReadOnly: true, PathFields: []string{"path", "file_path"}, CwdDefault: true})
+	register(Tool{Name: "Grep", ReadOnly: true, PathFields: []string{"path"}, CwdDefault: true, Summary: func(in map[string]interface{}) string {
+		return "Grep: " + truncate(str(in, "pattern"), 30)
+	}})
+	register(Tool{Name: "LS", ReadOnly: true, PathFields: []string{"path"}, CwdDefault: true})
+
+	register(Tool{Name: "Write", Edit: true, PathFields: []string{"file_path", "path"}})
+	register(Tool{Name: "Edit", Edit: true, PathFields: []string{"file_path", "path"}})
+	register(Tool{Name: "MultiEdit", Edit: true, PathFields: []string{"file_path"}, Summary: func(in map[string]interface{}) string {
+		edits, _ := in["edits"].([]interface{})
+		return "MultiEdit: " + shortPath(str(in, "file_path")) + " (x" + strconv.Itoa(len(edits)) + ")"
+	}})
+	register(Tool{Name: "NotebookEdit", Edit: true, PathFields: []string{"notebook_path"}})
+
+	register(Tool{Name: "WebFetch", ReadOnly: true, URL: "url", Summary: func(in map[string]interface{}) string {
+		u, err := urls.Normalize(str(in, "url"))
+		if err != nil {
+			return "WebFetch: (invalid URL)"
+		}
+		return "WebFetch: " + u.Host
+	}})
+	register(Tool{Name: "WebSearch", ReadOnly: true, Summary: func(in map[string]interface{}) string {
+		return "WebSearch: " + truncate(str(in, "query"), 40)
+	}})
+
+	register(Tool{Name: "Task", Summary: func(in map[string]interface{}) string {
+		agent := str(in, "subagent_type")
+		if agent == "" {
+			agent = "general-purpose"
+		}
+		return "Task(" + agent + "): " + truncate(str(in, "description"), 40)
+	}})
+	register(Tool{Name: "TodoWrite", ReadOnly: true})
+	register(Tool{Name: "ExitPlanMode"})
+	register(Tool{Name: "SlashCommand", Summary: func(in map[string]interface{}) string {
+		return "SlashCommand: " + truncate(str(in, "command"), 40)
+	}})
+	register(Tool{Name: "Skill", Summary: func(in map[string]interface{}) string {
+		return "Skill: " + str(in, "skill")
+	}})
+	register(Tool{Name: "AskUserQuestion", ReadOnly: true})
+	register(Tool{Name: "ListMcpResourcesTool", ReadOnly: true})
+	register(Tool{Name: "ReadMcpResourceTool", ReadOnly: true, Summary: func(in map[string]interface{}) string {
+		return "ReadMcpResource: " + str(in, "server") + " " + str(in, "uri")
+	}})
+}
+
+// Lookup returns the registered tool with the given name.
+func Lookup(name string) (Tool, bool) {
+	t, ok := registry[name]
+	return t, ok
+}
+
+// Path returns the path a call operates on, before normalization. Tools
+// that default to the working directory return cwd for an empty path.
+func (t Tool) Path(input map[string]interface{}, cwd string) (string, bool) {
+	if len(t.PathFields) == 0 {
+		return "", false
+	}
+	for _, field := range t.PathFields {
+		if p, ok := input[field].(string); ok && p != "" {
+			return p, true
+		}
+	}
+	if t.CwdDefault {
+		return cwd, true
+	}
+	return "", true
+}
+
+// Summarize describes a tool call in a few words for hook reasons.
+func Summarize(name string, input map[string]interface{}) string {
+	t, ok := registry[name]
+	if !ok {
+		return name
+	}
+	if t.Summary != nil {
+		return t.Summary(input)
+	}
+	if p, ok := t.Path(input, ""); ok {
+		return name + ": " + shortPath(p)
+	}
+	return name
+}
+
+func str(input map[string]interface{}, key string) string {
+	s, _ := input[key].(string)
+	return s
+}
+
+func truncate(s string, n int) string {
+	if len(s) > n {
+		return s[:n-3] + "..."
+	}
+	return s
+}
+
+// shortPath keeps the last element of a path.
+func shortPath(p string) string {
+	if idx := strings.LastIndex(p, "/"); idx >= 0 {
+		return "..." + p[idx:]
+	}
+	return p
+}
@@ -0,0 +1,162 @@
+package transcript
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/9roads/ccyolo/internal/secrets"
+	"github.com/9roads/ccyolo/internal/tools"
+)
+
+// tailBytes is how much of the end of a transcript is read. Recent turns
+// are all that matter, and transcripts grow to many megabytes.
+const tailBytes = 256 * 1024
+
+// maxActions is how many recent tool calls are summarized.
+const maxActions = 8
+
+// Intent is what the transcript says about the user's current request.
+type Intent struct {
+	LastUserMessage string
+	RecentActions   []string // oldest first
+}
+
+// entry is one line of a Claude Code transcript.
+type entry struct {
+	Type        string `json:"type"`
+	IsMeta      bool   `json:"isMeta"`
+	IsSidechain bool   `json:"isSidechain"`
+	Message     struct {
+		Role    string          `json:"role"`
+		Content json.RawMessage `json:"content"`
+	} `json:"message"`
+}
+
+type block struct {
+	Type  string                 `json:"type"`
+	Text  string                 `json:"text"`
+	Name  string                 `json:"name"`
+	Input map[string]interface{} `json:"input"`
+}
+
+// Read returns the last user message and the agent's tool calls since,
+// from the tail of a transcript file.
+func Read(path string) (Intent, error) {
+	var intent Intent
+	f, err := os.Open(path)
+	if err != nil {
+		return intent, err
+	}
+	defer f.Close()
+
+	if info, err := f.Stat(); err == nil && info.Size() > tailBytes {
+		f.Seek(-tailBytes, io.SeekEnd)
+	}
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return intent, err
+	}
+
+	lines := bytes.Split(data, []byte("\n"))
+	for i := len(lines) - 1; i >= 0; i-- {
+		var e entry
+		if json.Unmarshal(lines[i], &e) != nil || e.IsMeta || e.IsSidechain {
+			// The first line is usually cut off by the seek
+			continue
+		}
+
+		switch e.Type {
+		case "assistant":
+			var blocks []block
+			json.Unmarshal(e.Message.Content, &blocks)
+			for j := len(blocks) - 1; j >= 0 && len(intent.RecentActions) < maxActions; j-- {
+				if blocks[j].Type == "tool_use" {
+					intent.RecentActions = append(intent.RecentActions, tools.Summarize(blocks[j].Name, blocks[j].Input))
+				}
+			}
+		case "user":
+			if text := userText(e.Message.Content); text != "" {
+				intent.LastUserMessage = text
+				reverse(intent.RecentActions)
+				return intent, nil
+			}
+		}
+	}
+	reverse(intent.RecentActions)
+	return intent, nil
+}
+
+// userText returns what the user typed. Tool results come back as user
+// messages too and are skipped, as are command outputs Claude Code injects.
+func userText(content json.RawMessage) string {
+	var text string
+	if json.Unmarshal(content, &text) != nil {
+		var blocks []block
+		json.Unmarshal(content, &blocks)
+		var parts []string
+		for _, b := range blocks {
+			if b.Type == "text" {
+				parts = append(parts, b.Text)
+			}
+		}
+		text = strings.Join(parts, "\n")
+	}
+	text = strings.TrimSpace(text)
+	if strings.HasPrefix(text, "<local-command-") || strings.HasPrefix(text, "<command-") {
+		return ""
+	}
+	return text
+}
+
+// Describe renders the intent for the safety prompt within a budget of
+// roughly maxTokens tokens. Credentials are redacted.
+func (in Intent) Describe(maxTokens int) string {
+	if in.LastUserMessage == "" && len(in.RecentActions) == 0 {
+		return ""
+	}
+	budget := maxTokens * 4 // about four characters per token
+
+	var b strings.Builder
+	if in.LastUserMessage != "" {
+		msg := secrets.RedactText(in.LastUserMessage)
+		// The user's words get up to two thirds of the budget
+		if limit := budget * 2 / 3; len(msg) > limit {
+			msg = strings.ToValidUTF8(msg[:limit], "") + "..."
+		}
+		b.WriteString("Last user message:\n")
+		b.WriteString(indent(msg))
+		b.WriteString("\n")
+	}
+
+	if len(in.RecentActions) > 0 {
+		var lines []string
+		used := b.Len()
+		// Keep the most recent actions that fit
+		for i := len(in.RecentActions) - 1; i >= 0; i-- {
+			line := "- " + secrets.RedactText(in.RecentActions[i]) + "\n"
+			if used+len(line) > budget {
+				break
+			}
+			used += len(line)
+			lines = append([]string{line}, lines...)
+		}
+		if len(lines) > 0 {
+			b.WriteString("Agent actions since then:\n")
+			b.WriteString(strings.Join(lines, ""))
+		}
+	}
+	return b.String()
+}
+
+func indent(s string) string {
+	return "  " + strings.ReplaceAll(s, "\n", "\n  ")
+}
+
+func reverse(s []string) {
+	for i, j := 0, len(s)-1; i < j; i, j = i+1, j-1 {
+		s[i], s[j] = s[j], s[i]
+	}
+}
@@ -0,0 +1,126 @@
+package urls
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Normalize parses a URL the way a fetcher would resolve it, so that rules
+// see one spelling per host: the scheme defaults to https, the host is
+// lowercased without a trailing dot, user info, default ports and fragments
+// are dropped, and IPv4 addresses in decimal, hex or octal form are
+// rewritten as dotted quads.
+func Normalize(raw string) (*url.URL, error) {
+	raw = strings.TrimSpace(raw)
+	if !strings.Contains(raw, "://") {
+		raw = "https://" + raw
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, err
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("unsupported scheme %q", u.Scheme)
+	}
+
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	if host == "" {
+		return nil, fmt.Errorf("no host in %q", raw)
+	}
+	if ip := ParseIP(host); ip != nil {
+		host = ip.String()
+	}
+
+	port := u.Port()
+	if u.Scheme == "http" && port == "80" || u.Scheme == "https" && port == "443" {
+		port = ""
+	}
+	if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+	if port != "" {
+		host += ":" + port
+	}
+
+	u.Host = host
+	u.User = nil
+	u.Fragment = ""
+	u.RawFragment = ""
+	return u, nil
+}
+
+// ParseIP parses an IP address host, including the IPv4 forms inet_aton
+// accepts ("2130706433", "0x7f.1", "0177.0.0.1"). It returns nil for names.
+func ParseIP(host string) net.IP {
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+	if ip := net.ParseIP(host); ip != nil {
+		return ip
+	}
+
+	parts := strings.Split(host, ".")
+	if len(parts) > 4 {
+		return nil
+	}
+	nums := make([]uint64, len(parts))
+	for i, part := range parts {
+		n, err := strconv.ParseUint(part, 0, 32)
+		if err != nil {
+			return nil
+		}
+		nums[i] = n
+	}
+
+	// The last part fills the remaining bytes
+	var addr uint64
+	for i, n := range nums[:len(nums)-1] {
+		if n > 0xff {
+			return nil
+		}
+		addr |= n << (24 - 8*i)
+	}
+	last := nums[len(nums)-1]
+	if last >= 1<<(8*(5-len(nums))) {
+		return nil
+	}
+	addr |= last
+	return net.IPv4(byte(addr>>24), byte(addr>>16), byte(addr>>8), byte(addr))
+}
+
+// internalSuffixes are name suffixes that only resolve on private networks.
+var internalSuffixes = []string{
+	".localhost", ".local", ".internal", ".lan", ".home.arpa", ".corp", ".intranet",
+}
+
+// IsInternal reports whether a host names the local machine or a private
+// network: loopback, private and link-local addresses (which include cloud
+// metadata endpoints), "localhost", single-label names and internal
+// suffixes such as ".internal".
+func IsInternal(host string) bool {
+	if ip := ParseIP(host); ip != nil {
+		return ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() ||
+			ip.IsLinkLocalMulticast() || ip.IsUnspecified()
+	}
+	if host == "localhost" || !strings.Contains(host, ".") {
+		return true
+	}
+	for _, suffix := range internalSuffixes {
+		if strings.HasSuffix(host, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchDomain reports whether host matches a domain pattern. "example.com"
+// matches only that host; "*.example.com" matches it and any subdomain.
+func MatchDomain(host, pattern string) bool {
+	pattern = strings.TrimSuffix(strings.ToLower(pattern), ".")
+	if base, ok := strings.CutPrefix(pattern, "*."); ok {
+		return host == base || strings.HasSuffix(host, "."+base)
+	}
+	return host == pattern
+}
@@ -0,0 +1,157 @@
+package usage
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/9roads/ccyolo/internal/claude"
+	"github.com/9roads/ccyolo/internal/config"
+)
+
+// Record is one model call. Records are appended to a JSON lines file, so
+// concurrent hooks never lose each other's counts.
+type Record struct {
+	Time       int64   `json:"time"`
+	Preset     string  `json:"preset"`
+	Model      string  `json:"model"`
+	Tier       string  `json:"tier,omitempty"`
+	Input      int     `json:"input"`
+	Output     int     `json:"output"`
+	CacheWrite int     `json:"cache_write,omitempty"`
+	CacheRead  int     `json:"cache_read,omitempty"`
+	Cost       float64 `json:"cost,omitempty"` // estimated dollars, 0 if unknown
+	Priced     bool    `json:"priced,omitempty"`
+}
+
+// Path is where usage is recorded.
+func Path() string {
+	return filepath.Join(config.ConfigDir(), "usage.jsonl")
+}
+
+// Add records a model call.
+func Add(preset, model, tier string, u claude.Usage) {
+	r := Record{
+		Time:       time.Now().Unix(),
+		Preset:     preset,
+		Model:      model,
+		Tier:       tier,
+		Input:      u.InputTokens,
+		Output:     u.OutputTokens,
+		CacheWrite: u.CacheWriteTokens,
+		CacheRead:  u.CacheReadTokens,
+	}
+	r.Cost, r.Priced = u.Cost(model)
+
+	data, err := json.Marshal(r)
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(config.ConfigDir(), 0755); err != nil {
+		return
+	}
+	f, err := os.OpenFile(Path(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	// A single short write with O_APPEND lands whole
+	f.Write(append(data, '\n'))
+}
+
+// Load reads the records since a time. Corrupt lines are skipped.
+func Load(since time.Time) ([]Record, error) {
+	f, err := os.Open(Path())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	var records []Record
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var r Record
+		if json.Unmarshal(scanner.Bytes(), &r) != nil || r.Time < since.Unix() {
+			continue
+		}
+		records = append(records, r)
+	}
+	return records, scanner.Err()
+}
+
+// Total is the sum of a group of records.
+type Total struct {
+	Key        []string // group values, in the order asked for
+	Calls      int
+	Input      int
+	Output     int
+	CacheWrite int
+	CacheRead  int
+	Cost       float64
+	Unpriced   int // calls to models without a known price
+}
+
+func (t *Total) add(r Record) {
+	t.Calls++
+	t.Input += r.Input
+	t.Output += r.Output
+	t.CacheWrite += r.CacheWrite
+	t.CacheRead += r.CacheRead
+	t.Cost += r.Cost
+	if !r.Priced {
+		t.Unpriced++
+	}
+}
+
+// Group sums records by the given fields: "day", "preset", "model" or
+// "tier". Groups are sorted by key.
+func Group(records []Record, by []string) ([]Total, Total) {
+	groups := make(map[string]*Total)
+	var all Total
+	for _, r := range records {
+		key := make([]string, len(by))
+		for i, field := range by {
+			switch field {
+			case "day":
+				key[i] = time.Unix(r.Time, 0).Format("2006-01-02")
+			case "preset":
+				key[i] = r.Preset
+			case "model":
+				key[i] = r.Model
+			case "tier":
+				key[i] = r.Tier
+			}
+		}
+		id := strings.Join(key, "\x00")
+		if groups[id] == nil {
+			groups[id] = &Total{Key: key}
+		}
+		groups[id].add(r)
+		all.add(r)
+	}
+
+	totals := make([]Total, 0, len(groups))
+	for _, t := range groups {
+		totals = append(totals, *t)
+	}
+	sort.Slice(totals, func(i, j int) bool {
+		return strings.Join(totals[i].Key, "\x00") < strings.Join(totals[j].Key, "\x00")
+	})
+	return totals, all
+}
+
+// Clear deletes the recorded usage.
+func Clear() error {
+	err := os.Remove(Path())
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}